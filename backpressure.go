@@ -0,0 +1,134 @@
+package fernqclient
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// 默认的接收通道容量
+const DefaultReadBufferSize = 1024
+
+// OverflowPolicy 接收通道已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞等待消费者读取（默认）
+	//
+	// 阻塞期间不再从连接读取数据，读不到服务器的 Ping，改为定时主动发送 Pong，
+	// 避免服务器因收不到心跳响应判定客户端失联；间隔为 WithKeepalive 的间隔，未开启时为 1s。
+	// 服务器的发送缓冲积压过多时仍可能按慢消费者断开连接。
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest 丢弃新收到的消息
+	OverflowDropNewest
+	// OverflowDropOldest 丢弃通道中最早的消息，为新消息腾出空间
+	OverflowDropOldest
+	// OverflowDisconnect 断开连接，开启断线重连时触发重连
+	OverflowDisconnect
+)
+
+// WithReadBuffer 设置 Read() 通道的容量及通道已满时的处理策略
+//
+// 参数:
+//   - size: 通道容量，<= 0 时使用 DefaultReadBufferSize
+//   - policy: 通道已满时的处理策略
+//
+// 除 OverflowBlock 外，其余策略都不会阻塞读取协程；OverflowBlock 阻塞期间定时主动发送 Pong 保持连接。
+func WithReadBuffer(size int, policy OverflowPolicy) Option {
+	return func(o *options) {
+		o.readBufferSize = size
+		o.overflowPolicy = policy
+	}
+}
+
+// Stats 客户端运行统计
+type Stats struct {
+	MessagesDropped uint64 // 因接收通道已满而丢弃的消息数
+	SendsDropped    uint64 // 因发送队列已满而丢弃的消息数（SendQueueDrop 策略）
+}
+
+// 运行统计计数器
+type stats struct {
+	messagesDropped atomic.Uint64
+	sendsDropped    atomic.Uint64
+}
+
+// Stats 返回客户端自创建以来的运行统计
+func (c *Client) Stats() Stats {
+	return Stats{
+		MessagesDropped: c.stats.messagesDropped.Load(),
+		SendsDropped:    c.stats.sendsDropped.Load(),
+	}
+}
+
+// 获取接收通道容量
+func (o *options) readBufferSizeOrDefault() int {
+	if o.readBufferSize > 0 {
+		return o.readBufferSize
+	}
+	return DefaultReadBufferSize
+}
+
+// 按溢出策略将消息投递到接收通道或回调队列
+// 返回消息是否进入通道，需要断开连接时返回断开原因
+func deliver[T any](c *Client, closer *connCloser, ch chan T, v T) (bool, error) {
+	select {
+	case ch <- v:
+		return true, nil
+	default:
+	}
+
+	switch c.opts.overflowPolicy {
+	case OverflowDropNewest:
+		c.stats.messagesDropped.Add(1)
+		return false, nil
+	case OverflowDropOldest:
+		// 读取协程是唯一的写入方，腾出一个位置后重试
+		for {
+			select {
+			case old := <-ch:
+				c.stats.messagesDropped.Add(1)
+				// 被丢弃的回调不会执行
+				if it, ok := any(old).(dispatchItem); ok {
+					it.finish(false)
+				}
+			default:
+			}
+			select {
+			case ch <- v:
+				return true, nil
+			default:
+			}
+		}
+	case OverflowDisconnect:
+		c.stats.messagesDropped.Add(1)
+		return false, closer.close(ErrReadBufferFull)
+	}
+
+	// 阻塞期间心跳协程不计算丢失的 Pong
+	c.readBlocked.Store(true)
+	defer c.readBlocked.Store(false)
+	// 阻塞期间读不到服务器的 Ping，定时主动发送 Pong 表明客户端仍然在线
+	ticker := time.NewTicker(c.opts.blockedPongInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case ch <- v:
+			return true, nil
+		case <-c.ctx.Done():
+			return false, closer.close(ErrClosed)
+		case <-ticker.C:
+			if err := c.sendControl(pongFrame); err != nil {
+				log.Println("发送pong失败")
+			}
+		}
+	}
+}
+
+// 接收通道阻塞期间主动发送 Pong 的间隔
+func (o *options) blockedPongInterval() time.Duration {
+	if o.keepaliveInterval > 0 {
+		return o.keepaliveInterval
+	}
+	return time.Second
+}
@@ -0,0 +1,49 @@
+package fernqclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/fernqtest"
+	"github.com/xfs0205/fernqclient/server"
+)
+
+// 接收通道已满阻塞读取协程期间主动发送 Pong，服务器不会因心跳超时断开连接
+func TestOverflowBlockKeepsConnection(t *testing.T) {
+	s := fernqtest.NewServerConfig(t, server.Config{
+		OpenRooms:    true,
+		PingInterval: 50 * time.Millisecond,
+		PongTimeout:  50 * time.Millisecond,
+	})
+	c := connect(t, s, "edge",
+		fernqclient.WithKeepalive(20*time.Millisecond, 3),
+		fernqclient.WithReadBuffer(1, fernqclient.OverflowBlock))
+	sender := connect(t, s, "sender")
+
+	// 第一条消息填满接收通道，第二条使读取协程阻塞，阻塞时间超过服务器的心跳超时
+	for _, m := range []string{"a", "b"} {
+		if err := sender.Send("edge", []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(400 * time.Millisecond)
+
+	for _, want := range []string{"a", "b"} {
+		if msg := receive(t, c); string(msg.Message) != want {
+			t.Fatalf("收到 %q，期望 %q", msg.Message, want)
+		}
+	}
+	// 连接仍然有效时服务器可以继续转发
+	if err := sender.Send("edge", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg, ok := <-c.Read():
+		if !ok || string(msg.Message) != "c" {
+			t.Fatal("阻塞期间连接被服务器断开")
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("阻塞期间连接被服务器断开")
+	}
+}
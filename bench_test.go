@@ -0,0 +1,88 @@
+package fernqclient_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 基准测试使用的消息内容
+var benchMessage = bytes.Repeat([]byte("x"), 128)
+
+// 启动回显服务器：验证总是通过，每收到一条 P2P 消息就回复一条固定的接收消息
+//
+// 服务器只复用预先编码好的帧，测得的分配几乎全部来自客户端。
+func newEchoServer(b *testing.B) string {
+	b.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { ln.Close() })
+	verified, _ := codec.CreateRoomVerifyRes("bench", true, "")
+	reply, _ := codec.CreateReceiveMessage("peer", benchMessage)
+	pong, _ := codec.CreatePong()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var header [codec.HeaderTotal]byte
+				body := make([]byte, 64<<10)
+				for {
+					if _, err := io.ReadFull(conn, header[:]); err != nil {
+						return
+					}
+					n := int(binary.BigEndian.Uint32(header[0:4])) - codec.HeaderTotal
+					if _, err := io.ReadFull(conn, body[:n]); err != nil {
+						return
+					}
+					var out []byte
+					switch codec.FernqTypeCode(binary.BigEndian.Uint16(header[4:6])) {
+					case codec.TypeRoomVerify:
+						out = verified
+					case codec.TypeP2PRelay:
+						out = reply
+					case codec.TypePing:
+						out = pong
+					default:
+						continue
+					}
+					if _, err := conn.Write(out); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return "fernq://connect/" + ln.Addr().String() + "/uuid#bench?room_pass=pass"
+}
+
+// 客户端发送一条消息并收到一条消息的往返
+func BenchmarkClientSendReceive(b *testing.B) {
+	c := fernqclient.NewClient("bench")
+	if err := c.Connect(newEchoServer(b)); err != nil {
+		b.Fatal(err)
+	}
+	defer c.Stop()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := c.Send("peer", benchMessage); err != nil {
+			b.Fatal(err)
+		}
+		if _, ok := <-c.Read(); !ok {
+			b.Fatal("接收通道已关闭")
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package fernqclient
+
+import (
+	"sync"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+const maxPooledFrame = 64 << 10 // 超过该大小的帧缓冲不放回缓冲池
+
+// 发送帧缓冲池，帧写入连接后归还
+var framePool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 512)
+		return &b
+	},
+}
+
+// 心跳帧内容固定，只编码一次
+var (
+	pingFrame = codec.AppendPing(nil)
+	pongFrame = codec.AppendPong(nil)
+)
+
+// 从缓冲池获取帧缓冲
+func getFrameBuffer() *[]byte {
+	buf := framePool.Get().(*[]byte)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+// 归还帧缓冲
+func putFrameBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledFrame {
+		framePool.Put(buf)
+	}
+}
+
+// 使用缓冲池中的帧缓冲编码一帧并加入发送队列，写入连接后缓冲自动归还
+// 参数:
+//   - encode: 将帧追加到给定缓冲区并返回结果，通常为 codec.Append* 系列函数
+func (c *Client) writeFrame(encode func(dst []byte) []byte) error {
+	buf := getFrameBuffer()
+	*buf = encode(*buf)
+	return c.enqueue(*buf, buf)
+}
@@ -2,13 +2,16 @@ package fernqclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/xfs0205/fernqclient/codec"
 )
 
@@ -16,125 +19,288 @@ import (
 type FernqMessage struct {
 	From    string
 	Message []byte
+
+	Kind       codec.DeliveryKind // 投递方式，服务器未提供时为 codec.DeliveryUnknown
+	Target     string             // 发送方指定的目标：P2P 时为本客户端名称，扫描时为正则，广播时为发送方填写的值（本客户端固定为 "room"）
+	ReceivedAt time.Time          // 本地收到消息的时间
+	Seq        uint64             // 当前连接上收到的消息序号，从 1 开始，重连后重新计数
+	ID         string             // 可靠投递的消息id，重传时不变，普通消息为空
 }
 
 // 客户端
 type Client struct {
 	ClientName string // 客户端名称
 
+	opts options // 客户端选项
+
 	wg       sync.WaitGroup     // 等待组
 	ctx      context.Context    // 上下文
 	cancel   context.CancelFunc // 取消函数
 	readChan chan FernqMessage  // 读取通道
 
-	conn    net.Conn   // TCP连接
-	writeMu sync.Mutex // 写操作互斥锁
+	dispatcher        *dispatcher                           // 消息回调协程池
+	messageHandler    MessageHandler                        // 默认消息回调
+	fromHandlers      map[string]MessageHandler             // 按发送方注册的消息回调
+	kindHandlers      map[codec.DeliveryKind]MessageHandler // 按投递方式注册的消息回调
+	messageHandlersMu sync.RWMutex                          // 消息回调读写锁
 
-	isConnected bool       // 是否已连接
+	readBlocked atomic.Bool // 读取协程是否阻塞在接收通道上
+	stats       stats       // 运行统计
+
+	conn    net.Conn   // TCP连接
+	writeMu sync.Mutex // 连接字段互斥锁
+	queue   *sendQueue // 发送队列，由写协程写入连接
+
+	state       State      // 连接状态
+	running     bool       // 读取协程是否运行中（包括断线重连期间）
+	stopped     bool       // 调用 Stop() 后为 true，再次连接成功时清除
+	endpoints   []endpoint // 候选节点，断线重连时复用
+	current     int        // 当前节点在 endpoints 中的下标，-1 表示尚未连接成功
+	currentAddr string     // 当前节点的实际连接地址
 	statusMu    sync.Mutex // 状态访问互斥锁
 
+	listeners    map[int]func(StateEvent) // 状态变化订阅者
+	nextListener int                      // 下一个订阅者id
+	listenersMu  sync.Mutex               // 订阅者互斥锁
+
 	connMu sync.Mutex // 连接访问互斥锁
+
+	pending   map[string]*pendingCall         // 等待响应的请求，键为请求id
+	receipts  map[string]chan deliveryReceipt // 等待投递回执的确认发送，键为回执id
+	acks      map[uuid.UUID]pendingAck        // 等待确认的可靠消息，键为消息id
+	pendingMu sync.Mutex                      // 请求表互斥锁
+
+	dedup   map[string]*dedupWindow // 可靠投递的去重窗口，键为发送方
+	dedupMu sync.Mutex              // 去重窗口互斥锁，回调协程处理完成后也会访问
+
+	handlers   map[string]HandlerFunc // 请求处理函数，键为请求地址
+	handlersMu sync.RWMutex           // 处理函数表读写锁
+
+	connCancel   context.CancelFunc // 取消当前连接上请求处理函数的上下文
+	requestSlots chan struct{}      // 请求处理并发信号量
+
+	outboxWake chan struct{} // 发件箱有新消息时通知发送协程
+	detached   atomic.Uint64 // 连接断开次数，发件箱据此判断写出期间是否断线
 }
 
-// 安全发送信息
+// 安全发送信息，仅在连接可用时加入发送队列
 func (c *Client) safeWrite(data []byte) error {
 	c.writeMu.Lock()
-	defer c.writeMu.Unlock()
-	if c.conn == nil {
-		return fmt.Errorf("未连接")
+	conn := c.conn
+	c.writeMu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
 	}
-	_, err := c.conn.Write(data)
-	return err
+	return c.enqueue(data, nil)
 }
 
 // 读取信息协程
-func (c *Client) readLoop(xxbuff []byte) {
+func (c *Client) readLoop(dec *codec.Decoder) {
 	c.wg.Add(1)
 	go func() {
+		var reason error
 		defer func() {
-			c.writeMu.Lock()
-			if c.conn != nil {
-				c.conn.Close()
-				c.conn = nil
-			}
-			c.writeMu.Unlock()
+			// 关闭输出通道
+			close(c.readChan)
+			c.readChan = nil
+			c.dispatcher.close()
+			c.queue.close()
 
 			c.statusMu.Lock()
-			c.isConnected = false
+			c.running = false
 			c.statusMu.Unlock()
+			c.setState(StateClosed, reason)
 			c.wg.Done()
-
-			// 关闭输出通道
-			close(c.readChan)
-			c.readChan = nil
 		}()
 		for {
-			select {
-			case <-c.ctx.Done():
+			reason = c.serve(c.conn, dec)
+			c.detach()
+			dec.Release()
+
+			// 主动断开或未开启重连时退出
+			if c.ctx.Err() != nil || c.opts.reconnect == nil {
 				return
-			default:
-			}
-			buff := make([]byte, 1024)
-			// 设置读取超时时间
-			if err := c.conn.SetReadDeadline(time.Now().Add(time.Second * 5)); err != nil {
-				continue
 			}
-			n, err := c.conn.Read(buff)
+			c.setState(StateReconnecting, reason)
+			var err error
+			dec, err = c.reconnect()
 			if err != nil {
-				// 检查是否为超时错误
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					// log.Println("读取超时，重新设置超时并继续等待...")
-					continue // 超时后重新循环，不执行下面的数据处理
-				}
+				log.Printf("重连失败: %v", err)
+				reason = err
 				return
 			}
+		}
+	}()
+}
 
-			// 拼接数据
-			xxbuff = append(xxbuff, buff[:n]...)
-
-			// 循环处理数据
-			for {
-				msgType, body, remain, err := codec.Decode(xxbuff)
-				if err != nil {
-					if err == codec.ErrLength {
-						break
-					}
-					return
-				}
+// 处理单个连接上的数据，连接出错或主动断开时返回断开原因
+func (c *Client) serve(conn net.Conn, dec *codec.Decoder) error {
+	closer := &connCloser{conn: conn}
 
-				// 将剩余数据保存起来
-				xxbuff = remain
-
-				// 如果数据类型为心跳
-				if msgType == codec.TypePong || msgType == codec.TypePing {
-					// log.Println("收到心跳包")
-					// 创建并发送pong
-					pong, err := codec.CreatePong()
-					if err != nil {
-						log.Println("创建pong失败")
-						continue
-					}
-					if err := c.safeWrite(pong); err != nil {
-						log.Println("发送pong失败")
-						continue
-					}
-					continue
-				}
+	// 读取超时的轮询间隔，开启空闲超时时不超过空闲超时
+	poll := time.Second * 5
+	if idle := c.opts.readIdleTimeout; idle > 0 && idle < poll {
+		poll = idle
+	}
+	lastRead := time.Now()
+	var seq uint64 // 消息序号
 
-				// 解析数据
-				message, err := codec.DecodeReceiveMessagePB(body)
-				if err != nil {
-					log.Println("解析数据失败")
-					continue
-				}
-				// 添加到输出通道
-				c.readChan <- FernqMessage{
-					From:    message.From,
-					Message: message.Message,
+	// 当前连接的上下文，连接断开时取消，传给请求处理函数
+	connCtx, cancel := context.WithCancel(c.ctx)
+	c.writeMu.Lock()
+	c.connCancel = cancel
+	c.writeMu.Unlock()
+
+	// 启动写协程，返回前等待其退出，避免与重连后的写协程同时取用发送队列
+	writerDone := make(chan struct{})
+	writerStopped := c.writer(conn, closer, writerDone)
+	defer func() {
+		close(writerDone)
+		<-writerStopped
+	}()
+
+	// 开启主动心跳
+	pong := make(chan struct{}, 1)
+	if c.opts.keepaliveInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		c.keepalive(closer, pong, done)
+	}
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return closer.close(ErrClosed)
+		default:
+		}
+		// 设置读取超时时间
+		if err := conn.SetReadDeadline(time.Now().Add(poll)); err != nil {
+			return closer.close(c.closeReason(fmt.Errorf("设置读取超时失败: %w", err)))
+		}
+		msgType, body, err := dec.Next()
+		if err != nil {
+			// 检查是否为超时错误
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// 超过空闲超时仍未收到数据，视为连接已失效
+				if idle := c.opts.readIdleTimeout; idle > 0 && time.Since(lastRead) >= idle {
+					log.Println("读取空闲超时，断开连接")
+					return closer.close(ErrIdleTimeout)
 				}
+				// log.Println("读取超时，重新设置超时并继续等待...")
+				continue // 超时后重新循环，已读取的部分数据保留在解码器中
 			}
+			if errors.Is(err, codec.ErrFrameTooLarge) || errors.Is(err, codec.ErrMalformedHeader) {
+				return closer.close(fmt.Errorf("解析数据失败: %w", err))
+			}
+			return closer.close(c.closeReason(fmt.Errorf("读取数据失败: %w", err)))
 		}
-	}()
+		lastRead = time.Now()
+
+		// 收到心跳响应时通知心跳协程，未开启主动心跳时无人接收，不影响下面的处理
+		if msgType == codec.TypePong {
+			select {
+			case pong <- struct{}{}:
+			default:
+			}
+		}
+
+		// 如果数据类型为心跳，无论是否开启主动心跳都与之前一样回复心跳响应
+		if msgType == codec.TypePing || msgType == codec.TypePong {
+			// log.Println("收到心跳包")
+			// 发送pong
+			if err := c.sendControl(pongFrame); err != nil {
+				log.Println("发送pong失败")
+				continue
+			}
+			continue
+		}
+
+		// 如果数据类型为响应，交给等待中的请求
+		if msgType == codec.TypeResponseMessage {
+			c.dispatchResponse(body)
+			continue
+		}
+
+		// 如果数据类型为投递回执，交给等待中的确认发送
+		if msgType == codec.TypeDeliveryReceipt {
+			c.dispatchReceipt(body)
+			continue
+		}
+
+		// 如果数据类型为请求，交给注册的处理函数
+		if msgType == codec.TypeRequestMessage {
+			c.dispatchRequest(connCtx, body)
+			continue
+		}
+
+		// 解析数据
+		from, kind, target, message, err := codec.ParseReceiveMessageKind(body)
+		if err != nil {
+			log.Println("解析数据失败")
+			continue
+		}
+		// 可靠投递的确认交给等待中的发送方，不占用消息序号
+		if c.dispatchAck(from, message) {
+			continue
+		}
+		seq++
+		// 添加到输出通道
+		msg := FernqMessage{
+			From:       from,
+			Message:    message,
+			Kind:       kind,
+			Target:     target,
+			ReceivedAt: lastRead,
+			Seq:        seq,
+		}
+		// 可靠投递的数据消息去重后投递并回复确认
+		if c.opts.reliable != nil {
+			handled, err := c.receiveReliable(closer, msg)
+			if err != nil {
+				return err
+			}
+			if handled {
+				continue
+			}
+		}
+		if _, err := c.route(closer, msg, nil); err != nil {
+			return err
+		}
+	}
+}
+
+// 读取失败时的断开原因，调用 Stop() 导致的读取失败视为主动断开
+func (c *Client) closeReason(err error) error {
+	if c.ctx.Err() != nil {
+		return ErrClosed
+	}
+	return err
+}
+
+// 绑定验证成功的连接
+func (c *Client) attach(conn net.Conn) {
+	c.writeMu.Lock()
+	c.conn = conn
+	c.writeMu.Unlock()
+
+	c.setState(StateConnected, nil)
+}
+
+// 释放当前连接，并唤醒等待响应的请求
+func (c *Client) detach() {
+	c.writeMu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	if c.connCancel != nil {
+		c.connCancel()
+		c.connCancel = nil
+	}
+	c.writeMu.Unlock()
+	c.detached.Add(1)
+
+	c.closePending()
 }
 
 // Send P2P模式，发送消息到指定目标
@@ -146,11 +312,9 @@ func (c *Client) readLoop(xxbuff []byte) {
 //   - error: 发送过程中的错误
 func (c *Client) Send(to string, message []byte) error {
 	// 点对点发送：to为目标客户端名称
-	data, err := codec.CreateP2PRelay(to, message)
-	if err != nil {
-		return fmt.Errorf("创建P2P消息失败: %w", err)
-	}
-	return c.safeWrite(data)
+	return c.post(func(dst []byte) []byte {
+		return codec.AppendP2PRelay(dst, to, message)
+	})
 }
 
 // Broadcast 广播模式，将消息发送给房间内所有客户端，包括自己
@@ -160,11 +324,9 @@ func (c *Client) Send(to string, message []byte) error {
 // 返回值:
 //   - error: 发送过程中的错误
 func (c *Client) Broadcast(message []byte) error {
-	data, err := codec.CreateRoomBroadcast("room", message)
-	if err != nil {
-		return fmt.Errorf("创建广播消息失败: %w", err)
-	}
-	return c.safeWrite(data)
+	return c.post(func(dst []byte) []byte {
+		return codec.AppendRoomBroadcast(dst, "room", message)
+	})
 }
 
 // ScanSend 扫描发送模式(属于组播模式)，发送消息给指定正则表达式匹配的用户
@@ -180,11 +342,9 @@ func (c *Client) ScanSend(to string, message []byte) error {
 		return fmt.Errorf("无效的正则表达式 '%s': %w", to, err)
 	}
 
-	data, err := codec.CreateUserScan(to, message)
-	if err != nil {
-		return fmt.Errorf("创建扫描发送消息失败: %w", err)
-	}
-	return c.safeWrite(data)
+	return c.post(func(dst []byte) []byte {
+		return codec.AppendUserScan(dst, to, message)
+	})
 }
 
 // ScanOnlySend 扫描发送模式(属于单播模式)，发送消息给指定正则表达式匹配的用户中的随机一个
@@ -194,11 +354,9 @@ func (c *Client) UserScanSingle(to string, message []byte) error {
 		return fmt.Errorf("无效的正则表达式 '%s': %w", to, err)
 	}
 
-	data, err := codec.CreateUserScanSingle(to, message)
-	if err != nil {
-		return fmt.Errorf("创建扫描发送消息失败: %w", err)
-	}
-	return c.safeWrite(data)
+	return c.post(func(dst []byte) []byte {
+		return codec.AppendUserScanSingle(dst, to, message)
+	})
 }
 
 // Read 返回一个只读通道，用于接收来自服务器转发的消息
@@ -228,11 +386,16 @@ func (c *Client) UserScanSingle(to string, message []byte) error {
 // FernqMessage 字段说明:
 //   - From:    string 类型，表示发送方的客户端名称
 //   - Message: []byte 类型，原始消息内容字节数组，可根据业务需求转换为 string 或其他格式
+//   - Kind:    投递方式（P2P、广播、扫描组播、扫描单播），需要服务器支持
+//   - Target:  发送方指定的目标，如广播房间名、扫描正则，需要服务器支持
+//   - ReceivedAt / Seq: 本地收到消息的时间与当前连接上的序号
 //
 // 注意事项:
 //   - 通道在连接断开或调用 Stop() 后会被关闭，读取时需注意判断通道是否关闭（ok 值）
 //   - 该方法是线程安全的，可在多个 goroutine 中同时读取（但通常建议单 goroutine 消费）
 //   - Message 为原始字节数组，如需字符串形式需手动转换: string(msg.Message)
+//   - 通道容量及通道已满时的处理方式由 WithReadBuffer 配置，默认阻塞等待读取
+//   - 注册了 OnMessage / OnMessageFrom 回调时，由回调处理的消息不会发送到该通道
 func (c *Client) Read() <-chan FernqMessage {
 	return c.readChan
 }
@@ -242,11 +405,17 @@ func (c *Client) Read() <-chan FernqMessage {
 // 参数:
 //   - FQC: 服务器连接地址，fernq URL 格式
 //
-// URL 格式: fernq://connect/主机[:端口]/UUID#房间名[?room_pass=密码]
+// URL 格式: fernq://connect/主机[:端口][,主机[:端口]...]/UUID#房间名[?room_pass=密码]
+//
+// 列出多个节点时依次尝试，某个节点连接或验证失败时切换到下一个，详见 ConnectURLs
+//
+// 使用 fernqs:// 协议时通过 TLS 加密连接，可通过 WithTLS 自定义 TLS 配置；
+// 使用 fernq+ws:// 或 fernq+wss:// 协议时通过 WebSocket 连接，详见 WebSocketTransport
 //
 // 端口说明：
 //   - IP 地址（IPv4/IPv6）省略端口时，使用默认端口 9147
-//   - 域名省略端口时，保持无端口
+//   - 域名省略端口时，查询 _fernq._tcp.<域名> 的 SRV 记录，按优先级与权重依次尝试；
+//     没有 SRV 记录时使用默认端口 9147（可通过 WithResolver 替换解析器）
 //   - 使用非标准端口时需显式指定，如 :8080
 //
 // 示例:
@@ -259,9 +428,9 @@ func (c *Client) Read() <-chan FernqMessage {
 //	"fernq://connect/192.168.1.100:8080/uuid#room?room_pass=secret"
 //	// 实际连接: 192.168.1.100:8080
 //
-//	// 域名无端口（保持原样）
+//	// 域名无端口（SRV 记录，或默认 9147）
 //	"fernq://connect/room.example.com/uuid#room?room_pass=secret"
-//	// 实际连接: room.example.com
+//	// 实际连接: _fernq._tcp.room.example.com 指向的节点，或 room.example.com:9147
 //
 //	// 域名自定义端口
 //	"fernq://connect/room.example.com:8080/uuid#room?room_pass=secret"
@@ -283,147 +452,181 @@ func (c *Client) Read() <-chan FernqMessage {
 //   - 认证错误：房间密码错误
 //   - 房间错误：UUID 不存在或房间已关闭
 func (c *Client) Connect(FQC string) error {
-	c.connMu.Lock()
-	defer c.connMu.Unlock()
+	return c.ConnectContext(context.Background(), FQC)
+}
 
-	// 检查状态
-	c.statusMu.Lock()
-	if c.isConnected {
-		c.statusMu.Unlock()
-		return fmt.Errorf("已连接")
-	}
-	c.statusMu.Unlock()
-	// 生成验证信息
-	serverAddr, verify, err := codec.ValidateAndExtractAddress(c.ClientName, FQC)
-	if err != nil {
-		return fmt.Errorf("无效的FQC地址: %w", err)
+// ConnectContext 连接服务器，连接与房间验证过程受 ctx 控制
+//
+// 参数:
+//   - ctx: 仅作用于本次连接过程（拨号与等待验证结果），取消后立即中断连接并返回 ctx.Err()；
+//     连接建立后的生命周期由 Stop() 控制，不受 ctx 影响
+//   - FQC: 服务器连接地址，格式同 Connect
+//   - opts: 可选配置，如 WithDialTimeout、WithHandshakeTimeout、WithReadIdleTimeout，
+//     会覆盖 NewClient 中的同名配置，并在后续断线重连中沿用
+//
+// 使用方式:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	err := client.ConnectContext(ctx, FQC,
+//	    fernqclient.WithDialTimeout(3*time.Second),
+//	    fernqclient.WithReadIdleTimeout(time.Minute),
+//	)
+func (c *Client) ConnectContext(ctx context.Context, FQC string, opts ...Option) error {
+	return c.ConnectURLs(ctx, []string{FQC}, opts...)
+}
+
+// 使用传输方式建立底层连接
+func (c *Client) dial(ctx context.Context, transport Transport, serverAddr string) (net.Conn, error) {
+	if c.opts.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.dialTimeout)
+		defer cancel()
 	}
+	return transport.Dial(ctx, serverAddr)
+}
+
+// 建立连接并完成房间验证，返回验证成功的连接和继续读取该连接的解码器
+// 参数:
+//   - ep: 候选节点
+//   - serverAddr: 实际连接地址，域名节点可能来自 SRV 记录；TLS 证书校验仍使用节点原始主机名
+func (c *Client) handshake(ctx context.Context, ep endpoint, serverAddr string) (net.Conn, *codec.Decoder, error) {
+	scheme, verify := ep.scheme, ep.verify
 
 	// 创建连接
-	conn, err := net.Dial("tcp", serverAddr)
+	c.setState(StateConnecting, nil)
+	transport := c.opts.transportOrDefault()
+	if scheme == codec.SchemeFernqWS || scheme == codec.SchemeFernqWSS {
+		var err error
+		if transport, err = c.webSocketTransport(scheme, ep.address); err != nil {
+			return nil, nil, err
+		}
+	}
+	conn, err := c.dial(ctx, transport, serverAddr)
 	if err != nil {
-		return fmt.Errorf("连接服务器失败: %w", err)
+		return nil, nil, fmt.Errorf("连接服务器失败: %w", err)
+	}
+
+	// 设置验证的最长总时间（包括 TLS 握手）
+	if err := conn.SetDeadline(time.Now().Add(c.opts.handshakeTimeoutOrDefault())); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("设置验证超时失败: %w", err)
+	}
+
+	// fernqs:// 或设置了 TLS 配置时，使用 TLS 加密（WebSocket 传输的 TLS 由 wss 完成）
+	if scheme == codec.SchemeFernqs || (scheme == codec.SchemeFernq && c.opts.tlsConfig != nil) {
+		tlsConn, err := c.tlsHandshake(ctx, conn, ep.address)
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
 	}
+
+	// 连接成功，开始房间验证
+	c.setState(StateVerifying, nil)
+
+	// 上下文取消时关闭连接，中断阻塞中的读写
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+	defer stop()
+
 	// 连接成功，尝试验证
 	// 创建验证消息
 	_, err = conn.Write(verify)
 	if err != nil {
 		conn.Close()
-		return fmt.Errorf("发送验证消息失败: %w", err)
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		return nil, nil, fmt.Errorf("发送验证消息失败: %w", err)
 	}
-	// 设置最长总时间 3分钟
-	timeout := time.NewTimer(3 * time.Minute)
-	defer timeout.Stop()
 	// 读取数据
-	var xxbuff []byte
+	dec := codec.NewDecoder(conn, c.opts.maxFrameSize)
 	for {
-		select {
-		case <-timeout.C:
-			conn.Close()
-			return fmt.Errorf("验证超时")
-		default:
-		}
-
-		err := conn.SetReadDeadline(time.Now().Add(time.Second * 5))
-		if err != nil {
-			conn.Close()
-			return fmt.Errorf("设置读取超时失败: %w", err)
-		}
-
-		// 读取数据
-		buf := make([]byte, 1024)
-		n, err := conn.Read(buf)
+		msgType, body, err := dec.Next()
 		// 首先检查是否有错误
 		if err != nil {
+			conn.Close()
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
 			// 检查是否为超时错误
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				// log.Println("读取超时，重新设置超时并继续等待...")
-				continue // 超时后重新循环，不执行下面的数据处理
+				return nil, nil, ErrHandshakeTimeout
 			}
-			conn.Close()
-			return fmt.Errorf("读取数据失败: %w", err) // 退出循环，连接会被清理
+			if errors.Is(err, codec.ErrFrameTooLarge) || errors.Is(err, codec.ErrMalformedHeader) {
+				return nil, nil, fmt.Errorf("解析数据失败: %w", err)
+			}
+			return nil, nil, fmt.Errorf("读取数据失败: %w", err)
 		}
 
-		// 拼接数据
-		xxbuff = append(xxbuff, buf[:n]...)
-		for {
-			msgType, body, remain, err := codec.Decode(xxbuff)
+		// 判断是否是心跳
+		if msgType == codec.TypePong || msgType == codec.TypePing {
+			continue
+		}
+
+		// 判断是否是验证结果
+		if msgType == codec.TypeRoomVerifyRes {
+			result, resm, err := codec.ParseRoomVerifyRes(body)
 			if err != nil {
-				if err == codec.ErrLength {
-					break
-				}
 				conn.Close()
-				return fmt.Errorf("解析数据失败: %w", err)
+				return nil, nil, fmt.Errorf("解析验证结果失败: %w", err)
 			}
-			// 保存剩余数据
-			xxbuff = remain
-
-			// 判断是否是心跳
-			if msgType == codec.TypePong || msgType == codec.TypePing {
-				continue
+			if !result {
+				conn.Close()
+				return nil, nil, &AuthError{Msg: resm}
 			}
-
-			// 判断是否是验证结果
-			if msgType == codec.TypeRoomVerifyRes {
-				result, resm, err := codec.ParseRoomVerifyRes(body)
-				if err != nil {
-					conn.Close()
-					return fmt.Errorf("解析验证结果失败: %w", err)
-				}
-				if result {
-					// 验证成功
-
-					// 赋值到c.conn
-					c.writeMu.Lock()
-					c.conn = conn
-					c.writeMu.Unlock()
-
-					// 设置状态为已连接
-					c.statusMu.Lock()
-					c.isConnected = true
-					c.statusMu.Unlock()
-
-					// 添加上下文和取消函数
-					c.ctx, c.cancel = context.WithCancel(context.Background())
-
-					// 添加读输入通道
-					c.readChan = make(chan FernqMessage, 1024)
-
-					// 添加读协程
-					c.readLoop(xxbuff)
-
-					return nil
-				}
+			// 验证成功，停止监听上下文并清除验证超时
+			if !stop() {
+				return nil, nil, ctx.Err()
+			}
+			if err := conn.SetDeadline(time.Time{}); err != nil {
 				conn.Close()
-				return fmt.Errorf("房间验证失败: %s", resm)
+				return nil, nil, fmt.Errorf("设置验证超时失败: %w", err)
 			}
-			conn.Close()
-			return fmt.Errorf("验证失败")
+			return conn, dec, nil
 		}
+		conn.Close()
+		return nil, nil, fmt.Errorf("验证失败: 收到意外的消息类型 0x%X", uint16(msgType))
 	}
 }
 
-// 断开连接
+// 断开连接，开启发件箱时之后的发送返回 ErrClosed
 func (c *Client) Stop() error {
 	c.statusMu.Lock()
-	if !c.isConnected {
+	c.stopped = true
+	if !c.running {
 		c.statusMu.Unlock()
-		return fmt.Errorf("未连接")
+		return ErrNotConnected
 	}
 	c.statusMu.Unlock()
 	c.cancel()
 	c.writeMu.Lock()
-	c.conn.Close()
+	if c.conn != nil {
+		c.conn.Close()
+	}
 	c.writeMu.Unlock()
 	c.wg.Wait()
 	return nil
 }
 
 // 创建客户端
-func NewClient(clientName string) *Client {
-	return &Client{
-		ClientName:  clientName,
-		wg:          sync.WaitGroup{},
-		isConnected: false,
+//
+// 参数:
+//   - clientName: 客户端名称，房间内唯一
+//   - opts: 可选配置，如 WithReconnect(DefaultReconnectPolicy)
+func NewClient(clientName string, opts ...Option) *Client {
+	c := &Client{
+		ClientName: clientName,
+		wg:         sync.WaitGroup{},
+		state:      StateClosed,
+		current:    -1,
+		outboxWake: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(&c.opts)
 	}
+	return c
 }
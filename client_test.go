@@ -0,0 +1,112 @@
+package fernqclient_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 接受连接但从不回复验证结果的服务器，返回监听地址
+func silentServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		ln.Close()
+		<-done
+	})
+	go func() {
+		defer close(done)
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// 等待验证结果期间取消 ctx，ConnectContext 立即返回 ctx.Err()
+func TestConnectContextCancel(t *testing.T) {
+	url := "fernq://connect/" + silentServer(t) + "/uuid#test?room_pass=pass"
+	c := fernqclient.NewClient("edge", fernqclient.WithHandshakeTimeout(time.Minute))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.ConnectContext(ctx, url)
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ConnectContext 返回 %v，期望 context.DeadlineExceeded", err)
+	}
+	if elapsed > time.Second {
+		t.Fatalf("取消后 %v 才返回", elapsed)
+	}
+	if state := c.State(); state != fernqclient.StateClosed {
+		t.Fatalf("取消后状态为 %s", state)
+	}
+}
+
+// 消息携带投递方式、目标与当前连接上的序号，重连后序号重新计数
+func TestMessageMetadata(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv", fernqclient.WithReconnect(fastReconnect()))
+	sender := connect(t, s, "sender")
+
+	sends := []struct {
+		send   func() error
+		kind   codec.DeliveryKind
+		target string
+	}{
+		{func() error { return sender.Send("recv", []byte("p2p")) }, codec.DeliveryDirect, "recv"},
+		{func() error { return sender.Broadcast([]byte("broadcast")) }, codec.DeliveryBroadcast, "room"},
+		{func() error { return sender.ScanSend("rec.*", []byte("scan")) }, codec.DeliveryScan, "rec.*"},
+		{func() error { return sender.UserScanSingle("rec.*", []byte("single")) }, codec.DeliveryScanSingle, "rec.*"},
+	}
+	start := time.Now()
+	for i, tc := range sends {
+		if err := tc.send(); err != nil {
+			t.Fatal(err)
+		}
+		msg := receive(t, recv)
+		if msg.From != "sender" || msg.Kind != tc.kind || msg.Target != tc.target {
+			t.Fatalf("第 %d 条消息 From=%s Kind=%v Target=%q，期望 sender %v %q",
+				i+1, msg.From, msg.Kind, msg.Target, tc.kind, tc.target)
+		}
+		if msg.Seq != uint64(i+1) {
+			t.Fatalf("第 %d 条消息 Seq=%d", i+1, msg.Seq)
+		}
+		if msg.ReceivedAt.Before(start) || msg.ID != "" {
+			t.Fatalf("第 %d 条消息 ReceivedAt=%v ID=%q", i+1, msg.ReceivedAt, msg.ID)
+		}
+	}
+
+	// 重连后序号从 1 开始
+	s.CloseClientConnections()
+	waitState(t, recv, fernqclient.StateReconnecting)
+	waitState(t, recv, fernqclient.StateConnected)
+	waitState(t, sender, fernqclient.StateClosed)
+	again := connect(t, s, "again")
+	if err := again.Send("recv", []byte("p2p")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, recv); msg.Seq != 1 {
+		t.Fatalf("重连后 Seq=%d，期望 1", msg.Seq)
+	}
+}
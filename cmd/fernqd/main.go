@@ -0,0 +1,155 @@
+// fernqd 独立部署的 FernQ 服务器
+//
+// 使用方式:
+//
+//	fernqd -listen :9147 -room 'uuid#room=password' -ping 30s
+//	fernqd -listen :9147 -tls-cert server.crt -tls-key server.key -ws :8080 -open
+//
+// 参数:
+//   - -listen: TCP 监听地址，配置证书时为 TLS（客户端使用 fernqs://）
+//   - -room: 房间及密码，格式为 uuid#房间名=密码，可重复指定
+//   - -open: 允许客户端加入未配置的房间，密码取第一个加入的客户端提供的 room_pass
+//   - -ws: WebSocket 监听地址，路径为 /fernq，配置证书时为 wss（客户端使用 fernq+ws:// 或 fernq+wss://）
+//   - -ping / -pong-timeout: 服务器心跳间隔及等待响应的超时
+//   - -max-frame / -max-message: 最大帧长度与最大消息长度
+//
+// 状态码:
+//   - 请求与确认发送找不到接收方时回复 404，消息超过 -max-message 时回复 413
+//   - 普通消息（广播、P2P中转、扫描）没有回复通道，找不到接收方或超长时直接丢弃
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+	"github.com/xfs0205/fernqclient/server"
+)
+
+// 房间配置
+type roomFlag struct {
+	uuid, name, password string
+}
+
+func main() {
+	var (
+		listen      = flag.String("listen", ":"+codec.DefaultPort, "TCP 监听地址")
+		wsListen    = flag.String("ws", "", "WebSocket 监听地址，为空时不启用")
+		open        = flag.Bool("open", false, "允许客户端加入未配置的房间")
+		tlsCert     = flag.String("tls-cert", "", "TLS 证书文件")
+		tlsKey      = flag.String("tls-key", "", "TLS 私钥文件")
+		ping        = flag.Duration("ping", 30*time.Second, "服务器心跳间隔，0 表示不发送")
+		pongTimeout = flag.Duration("pong-timeout", 0, "心跳响应超时，0 表示与心跳间隔相同")
+		handshake   = flag.Duration("handshake-timeout", server.DefaultHandshakeTimeout, "等待房间验证消息的超时")
+		maxFrame    = flag.Int("max-frame", codec.DefaultMaxFrameSize, "最大帧长度（字节），超过时断开连接")
+		maxMessage  = flag.Int("max-message", 0, "最大消息长度（字节），超过时请求与确认发送回复 413，普通消息丢弃，0 表示不限制")
+		queueSize   = flag.Int("send-queue", server.DefaultSendQueueSize, "每个客户端的发送队列长度，队列满时断开该客户端")
+		rooms       []roomFlag
+	)
+	flag.Func("room", "房间及密码，格式为 uuid#房间名=密码，可重复指定", func(v string) error {
+		r, err := parseRoom(v)
+		if err != nil {
+			return err
+		}
+		rooms = append(rooms, r)
+		return nil
+	})
+	flag.Parse()
+
+	if len(rooms) == 0 && !*open {
+		log.Fatal("未配置任何房间，请使用 -room 指定房间或使用 -open 允许自动创建")
+	}
+
+	srv := server.New(server.Config{
+		OpenRooms:        *open,
+		MaxFrameSize:     *maxFrame,
+		MaxMessageSize:   *maxMessage,
+		HandshakeTimeout: *handshake,
+		SendQueueSize:    *queueSize,
+		PingInterval:     *ping,
+		PongTimeout:      *pongTimeout,
+	})
+	for _, r := range rooms {
+		srv.AddRoom(r.uuid, r.name, r.password)
+	}
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" || *tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatalf("加载 TLS 证书失败: %v", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	ln, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatalf("监听失败: %v", err)
+	}
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+	log.Printf("fernqd 监听 %s（TLS: %v）", ln.Addr(), tlsConfig != nil)
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	var httpSrv *http.Server
+	if *wsListen != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc(codec.DefaultWebSocketPath, srv.ServeWebSocket)
+		httpSrv = &http.Server{Addr: *wsListen, Handler: mux, TLSConfig: tlsConfig}
+		log.Printf("fernqd WebSocket 监听 %s%s", *wsListen, codec.DefaultWebSocketPath)
+		go func() {
+			if tlsConfig != nil {
+				errc <- httpSrv.ListenAndServeTLS("", "")
+			} else {
+				errc <- httpSrv.ListenAndServe()
+			}
+		}()
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case s := <-sig:
+		log.Printf("收到信号 %v，正在关闭", s)
+	case err := <-errc:
+		if !errors.Is(err, server.ErrServerClosed) && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("服务异常退出: %v", err)
+		}
+	}
+
+	if httpSrv != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		httpSrv.Shutdown(ctx)
+		cancel()
+	}
+	srv.Close()
+}
+
+// 解析 uuid#房间名=密码
+func parseRoom(v string) (roomFlag, error) {
+	key, password, ok := strings.Cut(v, "=")
+	if !ok {
+		return roomFlag{}, fmt.Errorf("缺少密码: %s", v)
+	}
+	uuid, name, ok := strings.Cut(key, "#")
+	if !ok || uuid == "" || name == "" {
+		return roomFlag{}, fmt.Errorf("房间格式应为 uuid#房间名=密码: %s", v)
+	}
+	return roomFlag{uuid: uuid, name: name, password: password}, nil
+}
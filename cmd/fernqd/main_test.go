@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestParseRoom(t *testing.T) {
+	tests := []struct {
+		in   string
+		want roomFlag
+		ok   bool
+	}{
+		{"uuid#room=secret", roomFlag{"uuid", "room", "secret"}, true},
+		{"uuid#room=", roomFlag{"uuid", "room", ""}, true},
+		{"uuid#room=a=b", roomFlag{"uuid", "room", "a=b"}, true},
+		{"uuid#room", roomFlag{}, false},
+		{"uuid=secret", roomFlag{}, false},
+		{"#room=secret", roomFlag{}, false},
+		{"uuid#=secret", roomFlag{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseRoom(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseRoom(%q) = %+v, %v", tt.in, got, err)
+		}
+	}
+}
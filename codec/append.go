@@ -0,0 +1,145 @@
+package codec
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// ====================== 追加编码 ======================
+//
+// Append* 系列函数将帧直接编码到调用方提供的缓冲区末尾并返回扩展后的切片，
+// 配合可复用的缓冲区使用时，编码过程不产生额外的内存分配。
+// 输出与对应的 Create* 函数完全一致。
+
+// AppendFrame 将 msgType + payload 打包成帧并追加到 dst
+func AppendFrame(dst []byte, msgType FernqTypeCode, payload []byte) []byte {
+	dst = slices.Grow(dst, HeaderTotal+len(payload))
+	dst = appendHeader(dst, msgType, len(payload))
+	return append(dst, payload...)
+}
+
+// AppendMessage 将 protobuf 消息直接编码为帧并追加到 dst，省去中间缓冲区的拷贝
+func AppendMessage(dst []byte, msgType FernqTypeCode, m proto.Message) ([]byte, error) {
+	start := len(dst)
+	dst = append(dst, make([]byte, HeaderTotal)...)
+	dst, err := proto.MarshalOptions{}.MarshalAppend(dst, m)
+	if err != nil {
+		return dst[:start], err
+	}
+	binary.BigEndian.PutUint32(dst[start:start+4], uint32(len(dst)-start))
+	binary.BigEndian.PutUint16(dst[start+4:start+6], uint16(msgType))
+	return dst, nil
+}
+
+// AppendRoomBroadcast 追加房间广播帧
+func AppendRoomBroadcast(dst []byte, room string, message []byte) []byte {
+	return appendTransit(dst, TypeRoomBroadcast, room, message)
+}
+
+// AppendUserScan 追加扫描组播帧
+func AppendUserScan(dst []byte, scan string, message []byte) []byte {
+	return appendTransit(dst, TypeUserScan, scan, message)
+}
+
+// AppendUserScanSingle 追加扫描单播帧
+func AppendUserScanSingle(dst []byte, scan string, message []byte) []byte {
+	return appendTransit(dst, TypeUserScanSingle, scan, message)
+}
+
+// AppendP2PRelay 追加P2P中转帧
+func AppendP2PRelay(dst []byte, target string, message []byte) []byte {
+	return appendTransit(dst, TypeP2PRelay, target, message)
+}
+
+// AppendPing 追加心跳帧
+func AppendPing(dst []byte) []byte {
+	return appendHeader(dst, TypePing, 0)
+}
+
+// AppendPong 追加心跳响应帧
+func AppendPong(dst []byte) []byte {
+	return appendHeader(dst, TypePong, 0)
+}
+
+// ParseReceiveMessage 解析接收消息，返回发送方和消息内容
+//
+// 与 DecodeReceiveMessagePB 结果一致，但不创建中间的 ReceiveMessage 对象；
+// 返回的 message 为独立拷贝，不引用 data。
+func ParseReceiveMessage(data []byte) (from string, message []byte, err error) {
+	from, _, _, message, err = ParseReceiveMessageKind(data)
+	return from, message, err
+}
+
+// ParseReceiveMessageKind 解析接收消息，额外返回投递方式和发送方指定的目标
+//
+// 服务器未提供投递信息时 kind 为 DeliveryUnknown，target 为空。
+func ParseReceiveMessageKind(data []byte) (from string, kind DeliveryKind, target string, message []byte, err error) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return "", 0, "", nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case (num == 1 || num == 2 || num == 4) && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return "", 0, "", nil, protowire.ParseError(n)
+			}
+			switch num {
+			case 1:
+				from = string(v)
+			case 2:
+				message = append([]byte(nil), v...)
+			case 4:
+				target = string(v)
+			}
+			data = data[n:]
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return "", 0, "", nil, protowire.ParseError(n)
+			}
+			kind = DeliveryKind(int32(v))
+			data = data[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return "", 0, "", nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+		}
+	}
+	return from, kind, target, message, nil
+}
+
+// 追加帧头
+func appendHeader(dst []byte, msgType FernqTypeCode, payloadLen int) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(HeaderTotal+payloadLen))
+	return binary.BigEndian.AppendUint16(dst, uint16(msgType))
+}
+
+// 追加中转消息帧，直接按 protobuf 线格式编码 TransitMessage，与 proto.Marshal 的输出一致
+func appendTransit(dst []byte, msgType FernqTypeCode, target string, message []byte) []byte {
+	size := 0
+	if len(target) > 0 {
+		size += protowire.SizeTag(1) + protowire.SizeBytes(len(target))
+	}
+	if len(message) > 0 {
+		size += protowire.SizeTag(2) + protowire.SizeBytes(len(message))
+	}
+	dst = slices.Grow(dst, HeaderTotal+size)
+	dst = appendHeader(dst, msgType, size)
+	if len(target) > 0 {
+		dst = protowire.AppendTag(dst, 1, protowire.BytesType)
+		dst = protowire.AppendString(dst, target)
+	}
+	if len(message) > 0 {
+		dst = protowire.AppendTag(dst, 2, protowire.BytesType)
+		dst = protowire.AppendBytes(dst, message)
+	}
+	return dst
+}
@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// 基准测试使用的消息内容
+var benchMessage = bytes.Repeat([]byte("x"), 128)
+
+// 改造前的编码方式：先序列化 protobuf，再由 Encode 拷贝到新的帧中
+func BenchmarkP2PRelayLegacy(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		payload, err := EncodeTransitMessagePB(&TransitMessage{Target: "worker-1", Message: benchMessage})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := Encode(TypeP2PRelay, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateP2PRelay(b *testing.B) {
+	b.ReportAllocs()
+	for range b.N {
+		if _, err := CreateP2PRelay("worker-1", benchMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// 复用帧缓冲，与客户端发送路径一致
+func BenchmarkAppendP2PRelay(b *testing.B) {
+	b.ReportAllocs()
+	buf := make([]byte, 0, 512)
+	for range b.N {
+		buf = AppendP2PRelay(buf[:0], "worker-1", benchMessage)
+	}
+}
+
+// 循环重复同一段数据流的 io.Reader，每次最多返回 chunk 字节
+type loopReader struct {
+	data  []byte
+	off   int
+	chunk int
+}
+
+func (r *loopReader) Read(p []byte) (int, error) {
+	if len(p) > r.chunk {
+		p = p[:r.chunk]
+	}
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+// 由 64 条接收消息组成的数据流
+func receiveStream(b *testing.B) []byte {
+	var stream []byte
+	for range 64 {
+		frame, err := CreateReceiveMessage("worker-1", benchMessage)
+		if err != nil {
+			b.Fatal(err)
+		}
+		stream = append(stream, frame...)
+	}
+	return stream
+}
+
+// 改造前的读取方式：每次读取分配 1024 字节，拼接后用 Decode 拆帧，再完整反序列化 protobuf
+func BenchmarkReceiveLegacy(b *testing.B) {
+	r := &loopReader{data: receiveStream(b), chunk: 1024}
+	var pending []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; {
+		buf := make([]byte, 1024)
+		n, _ := r.Read(buf)
+		pending = append(pending, buf[:n]...)
+		for i < b.N {
+			_, body, remain, err := Decode(pending)
+			if err == ErrLength {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+			pending = remain
+			if _, err := DecodeReceiveMessagePB(body); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	}
+}
+
+// 复用读缓冲的 Decoder，正文用 ParseReceiveMessage 解析
+func BenchmarkReceiveDecoder(b *testing.B) {
+	dec := NewDecoder(&loopReader{data: receiveStream(b), chunk: 1024}, 0)
+	defer dec.Release()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		_, body, err := dec.Next()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := ParseReceiveMessage(body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -22,11 +22,21 @@ func Encode(msgType FernqTypeCode, payload []byte) ([]byte, error) {
 }
 
 // Decode 解出一帧，返回 (msgType, 正文, 剩余数据, 错误)
+//
+// 数据不足一帧时返回 ErrLength，调用方应继续读取后重试；
+// 帧长度小于帧头长度时返回 ErrMalformedHeader，超过 DefaultMaxFrameSize 时返回 ErrFrameTooLarge，
+// 此时数据流已无法恢复，应关闭连接。需要自定义上限时使用 Decoder。
 func Decode(data []byte) (FernqTypeCode, []byte, []byte, error) {
 	if len(data) < HeaderTotal {
 		return 0, nil, data, ErrLength
 	}
 	total := binary.BigEndian.Uint32(data[0:4])
+	if total < HeaderTotal {
+		return 0, nil, data, ErrMalformedHeader
+	}
+	if total > DefaultMaxFrameSize {
+		return 0, nil, data, ErrFrameTooLarge
+	}
 	if uint32(len(data)) < total {
 		return 0, nil, data, ErrLength
 	}
@@ -0,0 +1,133 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+const (
+	DefaultMaxFrameSize = 16 << 20 // 默认的最大帧长度 16MB（包括帧头）
+	minDecoderBuffer    = 4 << 10  // 读缓冲的初始大小
+	maxPooledBuffer     = 64 << 10 // 超过该大小的读缓冲不放回缓冲池，避免长期占用大块内存
+)
+
+// 读缓冲池，连接断开后读缓冲可被下一个 Decoder 复用
+var decoderBufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, minDecoderBuffer)
+		return &b
+	},
+}
+
+// Decoder 从 io.Reader 中逐帧读取数据
+//
+// 与 Decode 不同，Decoder 会在读取帧体之前校验帧头：
+//   - 帧长度小于帧头长度时返回 ErrMalformedHeader
+//   - 帧长度超过上限时返回 ErrFrameTooLarge，不会为其分配内存
+//
+// 读缓冲在帧之间复用，Next 返回的正文仅在下一次调用 Next 之前有效。
+// 不再使用时调用 Release 将读缓冲归还缓冲池。
+type Decoder struct {
+	r     io.Reader
+	max   int    // 最大帧长度
+	buf   []byte // 读缓冲
+	start int    // 未处理数据的起始位置
+	end   int    // 未处理数据的结束位置
+}
+
+// NewDecoder 创建帧解码器
+// 参数:
+//   - r: 数据来源，通常为网络连接
+//   - maxFrameSize: 最大帧长度（包括帧头），<= 0 时使用 DefaultMaxFrameSize
+func NewDecoder(r io.Reader, maxFrameSize int) *Decoder {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &Decoder{
+		r:   r,
+		max: maxFrameSize,
+		buf: *decoderBufPool.Get().(*[]byte),
+	}
+}
+
+// Release 将读缓冲归还缓冲池
+//
+// 注意事项:
+//   - 调用后 Decoder 不可再使用，之前 Next 返回的正文也随之失效
+//   - 重复调用是安全的
+func (d *Decoder) Release() {
+	buf := d.buf
+	d.buf, d.start, d.end = nil, 0, 0
+	if buf == nil || cap(buf) > maxPooledBuffer {
+		return
+	}
+	buf = buf[:cap(buf)]
+	decoderBufPool.Put(&buf)
+}
+
+// Next 读取下一帧，返回 (msgType, 正文, 错误)
+//
+// 读取出错时（包括读取超时）已读到的部分数据会保留，可以再次调用 Next 继续读取；
+// 返回 ErrFrameTooLarge 或 ErrMalformedHeader 后数据流已无法恢复，应关闭连接。
+func (d *Decoder) Next() (FernqTypeCode, []byte, error) {
+	for {
+		need := HeaderTotal
+		if d.end-d.start >= HeaderTotal {
+			total := binary.BigEndian.Uint32(d.buf[d.start : d.start+4])
+			if total < HeaderTotal {
+				return 0, nil, ErrMalformedHeader
+			}
+			if uint64(total) > uint64(d.max) {
+				return 0, nil, ErrFrameTooLarge
+			}
+			if d.end-d.start >= int(total) {
+				frame := d.buf[d.start : d.start+int(total)]
+				d.start += int(total)
+				if d.start == d.end {
+					d.start, d.end = 0, 0
+				}
+				msgType := binary.BigEndian.Uint16(frame[4:6])
+				return FernqTypeCode(msgType), frame[HeaderTotal:], nil
+			}
+			need = int(total)
+		}
+
+		d.reserve(need)
+		n, err := d.r.Read(d.buf[d.end:])
+		d.end += n
+		if n == 0 && err != nil {
+			if err == io.EOF && d.end > d.start {
+				return 0, nil, io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+	}
+}
+
+// Buffered 返回已读取但尚未解析的数据
+func (d *Decoder) Buffered() []byte {
+	return d.buf[d.start:d.end]
+}
+
+// 确保缓冲区能容纳 need 字节的完整帧，并留有读取空间
+func (d *Decoder) reserve(need int) {
+	if d.start+need <= len(d.buf) && d.end < len(d.buf) {
+		return
+	}
+	// 将未处理的数据移到缓冲区开头
+	if need <= len(d.buf) {
+		d.end = copy(d.buf, d.buf[d.start:d.end])
+		d.start = 0
+		return
+	}
+	// 扩容，按倍数增长以减少拷贝次数
+	size := max(len(d.buf)*2, need)
+	if size > d.max {
+		size = max(d.max, need)
+	}
+	buf := make([]byte, size)
+	d.end = copy(buf, d.buf[d.start:d.end])
+	d.start = 0
+	d.buf = buf
+}
@@ -0,0 +1,233 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// 读取超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// 按预设的分段返回数据的 io.Reader，分段为 nil 时返回一次超时错误
+type chunkReader struct {
+	chunks [][]byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	c := r.chunks[0]
+	if c == nil {
+		r.chunks = r.chunks[1:]
+		return 0, timeoutError{}
+	}
+	n := copy(p, c)
+	if n == len(c) {
+		r.chunks = r.chunks[1:]
+	} else {
+		r.chunks[0] = c[n:]
+	}
+	return n, nil
+}
+
+// 只有帧头的帧，长度字段为 total
+func header(total uint32, msgType FernqTypeCode) []byte {
+	h := binary.BigEndian.AppendUint32(nil, total)
+	return binary.BigEndian.AppendUint16(h, uint16(msgType))
+}
+
+// 正文长度为 size 的帧，正文每个字节都是帧类型的低 8 位
+func frame(t *testing.T, msgType FernqTypeCode, size int) []byte {
+	t.Helper()
+	f, err := Encode(msgType, bytes.Repeat([]byte{byte(msgType)}, size))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// 检查 Next 返回的帧
+func expectFrame(t *testing.T, d *Decoder, msgType FernqTypeCode, size int) {
+	t.Helper()
+	gotType, body, err := d.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if gotType != msgType || len(body) != size || (size > 0 && body[0] != byte(msgType)) {
+		t.Fatalf("收到类型 0x%X 长度 %d，期望 0x%X 长度 %d", uint16(gotType), len(body), uint16(msgType), size)
+	}
+}
+
+// 帧头与帧体被拆分到多次读取中，包括超过初始读缓冲的大帧
+func TestDecoderPartial(t *testing.T) {
+	small, large := frame(t, TypeP2PRelay, 10), frame(t, TypeReceiveMessage, 3*minDecoderBuffer)
+	stream := append(append(append([]byte{}, small...), large...), frame(t, TypePing, 0)...)
+
+	tests := []struct {
+		name   string
+		chunks [][]byte
+	}{
+		{"one read", [][]byte{stream}},
+		{"split header", [][]byte{stream[:3], stream[3:5], stream[5:]}},
+		{"split body", [][]byte{stream[:8], stream[8 : len(small)+100], stream[len(small)+100:]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(&chunkReader{chunks: tt.chunks}, 0)
+			defer d.Release()
+			expectFrame(t, d, TypeP2PRelay, 10)
+			expectFrame(t, d, TypeReceiveMessage, 3*minDecoderBuffer)
+			expectFrame(t, d, TypePing, 0)
+			if _, _, err := d.Next(); err != io.EOF {
+				t.Fatalf("数据结束时返回 %v，期望 io.EOF", err)
+			}
+		})
+	}
+
+	// 逐字节读取
+	d := NewDecoder(&loopReader{data: stream, chunk: 1}, 0)
+	defer d.Release()
+	for range 3 {
+		expectFrame(t, d, TypeP2PRelay, 10)
+		expectFrame(t, d, TypeReceiveMessage, 3*minDecoderBuffer)
+		expectFrame(t, d, TypePing, 0)
+	}
+}
+
+// 帧中间连接断开时返回 io.ErrUnexpectedEOF
+func TestDecoderUnexpectedEOF(t *testing.T) {
+	f := frame(t, TypeP2PRelay, 10)
+	d := NewDecoder(bytes.NewReader(f[:len(f)-1]), 0)
+	defer d.Release()
+	if _, _, err := d.Next(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("返回 %v，期望 io.ErrUnexpectedEOF", err)
+	}
+}
+
+// 读取超时后已读到的部分数据保留，再次调用 Next 继续读取
+func TestDecoderResumeAfterTimeout(t *testing.T) {
+	f := frame(t, TypeP2PRelay, 100)
+	r := &chunkReader{chunks: [][]byte{f[:4], nil, f[4:50], nil, f[50:]}}
+	d := NewDecoder(r, 0)
+	defer d.Release()
+
+	for range 2 {
+		_, _, err := d.Next()
+		var ne net.Error
+		if !errors.As(err, &ne) || !ne.Timeout() {
+			t.Fatalf("返回 %v，期望超时错误", err)
+		}
+	}
+	expectFrame(t, d, TypeP2PRelay, 100)
+}
+
+// 帧长度超过上限时返回 ErrFrameTooLarge，不为其分配读缓冲
+func TestDecoderFrameTooLarge(t *testing.T) {
+	const max = 1 << 20
+	tests := []struct {
+		name  string
+		total uint32
+	}{
+		{"over max", max + 1},
+		{"4GB", 1<<32 - 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(bytes.NewReader(header(tt.total, TypeP2PRelay)), max)
+			defer d.Release()
+			if _, _, err := d.Next(); !errors.Is(err, ErrFrameTooLarge) {
+				t.Fatalf("返回 %v，期望 ErrFrameTooLarge", err)
+			}
+			if len(d.buf) > maxPooledBuffer {
+				t.Fatalf("读缓冲增长到 %d 字节", len(d.buf))
+			}
+		})
+	}
+
+	// 恰好等于上限的帧可以读取
+	d := NewDecoder(bytes.NewReader(frame(t, TypeP2PRelay, 100)), 100+HeaderTotal)
+	defer d.Release()
+	expectFrame(t, d, TypeP2PRelay, 100)
+}
+
+// 帧长度小于帧头长度时返回 ErrMalformedHeader
+func TestDecoderMalformedHeader(t *testing.T) {
+	for total := uint32(0); total < HeaderTotal; total++ {
+		d := NewDecoder(bytes.NewReader(header(total, TypeP2PRelay)), 0)
+		if _, _, err := d.Next(); !errors.Is(err, ErrMalformedHeader) {
+			t.Fatalf("长度 %d 返回 %v，期望 ErrMalformedHeader", total, err)
+		}
+		d.Release()
+	}
+}
+
+// 读缓冲在帧之间复用，Release 后归还缓冲池
+func TestDecoderBufferReuse(t *testing.T) {
+	stream := frame(t, TypeReceiveMessage, 128)
+	d := NewDecoder(&loopReader{data: stream, chunk: len(stream)}, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, _, err := d.Next(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("每帧分配 %.1f 次，期望 0", allocs)
+	}
+
+	d.Release()
+	d.Release() // 重复调用是安全的
+	if d.buf != nil || len(d.Buffered()) != 0 {
+		t.Fatal("Release 后读缓冲未清空")
+	}
+
+	// 归还的读缓冲被下一个 Decoder 取出；缓冲池可能随时丢弃对象，只要求大多数情况下复用
+	reused := 0
+	for range 100 {
+		d := NewDecoder(nil, 0)
+		buf := &d.buf[:1][0]
+		d.Release()
+		d = NewDecoder(nil, 0)
+		if &d.buf[:1][0] == buf {
+			reused++
+		}
+		d.Release()
+	}
+	if reused < 50 {
+		t.Fatalf("100 次中只有 %d 次复用了读缓冲", reused)
+	}
+}
+
+// Decode 与 Decoder 使用相同的帧头校验
+func TestDecode(t *testing.T) {
+	f := frame(t, TypeP2PRelay, 10)
+	msgType, body, remain, err := Decode(append(append([]byte{}, f...), 1, 2))
+	if err != nil || msgType != TypeP2PRelay || len(body) != 10 || !bytes.Equal(remain, []byte{1, 2}) {
+		t.Fatalf("Decode = 0x%X %d %v %v", uint16(msgType), len(body), remain, err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"short header", f[:4], ErrLength},
+		{"short body", f[:len(f)-1], ErrLength},
+		{"malformed", header(5, TypeP2PRelay), ErrMalformedHeader},
+		{"too large", header(DefaultMaxFrameSize+1, TypeP2PRelay), ErrFrameTooLarge},
+		{"4GB", header(1<<32-1, TypeP2PRelay), ErrFrameTooLarge},
+	}
+	for _, tt := range tests {
+		if _, _, remain, err := Decode(tt.data); !errors.Is(err, tt.err) || len(remain) != len(tt.data) {
+			t.Errorf("%s: 返回 %v，期望 %v 且保留全部数据", tt.name, err, tt.err)
+		}
+	}
+}
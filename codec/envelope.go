@@ -0,0 +1,51 @@
+package codec
+
+import "bytes"
+
+// ====================== 可靠投递信封 ======================
+//
+// 可靠投递的消息在普通消息内容外包装一层信封:
+//
+//	| 魔数 4 字节 | 类型 1 字节 | 消息id 16 字节 | 消息内容（仅数据信封） |
+//
+// 信封对服务器透明，由收发双方的客户端解析。
+
+// EnvelopeKind 信封类型
+type EnvelopeKind byte
+
+const (
+	EnvelopeData EnvelopeKind = 0x01 // 数据，接收方处理后回复确认
+	EnvelopeAck  EnvelopeKind = 0x02 // 确认，不携带消息内容
+)
+
+const envelopeHeader = 4 + 1 + 16 // 信封头长度
+
+// 信封魔数
+var envelopeMagic = []byte{0xF7, 'F', 'Q', 'R'}
+
+// AppendEnvelope 将信封追加到 dst
+// 参数:
+//   - kind: 信封类型
+//   - id: 消息id，确认信封使用被确认消息的id
+//   - payload: 消息内容，确认信封传入 nil
+func AppendEnvelope(dst []byte, kind EnvelopeKind, id [16]byte, payload []byte) []byte {
+	dst = append(dst, envelopeMagic...)
+	dst = append(dst, byte(kind))
+	dst = append(dst, id[:]...)
+	return append(dst, payload...)
+}
+
+// ParseEnvelope 解析信封，不是信封时 ok 为 false
+//
+// 返回的 payload 引用 data，不做拷贝。
+func ParseEnvelope(data []byte) (kind EnvelopeKind, id [16]byte, payload []byte, ok bool) {
+	if len(data) < envelopeHeader || !bytes.Equal(data[:4], envelopeMagic) {
+		return 0, id, nil, false
+	}
+	kind = EnvelopeKind(data[4])
+	if kind != EnvelopeData && kind != EnvelopeAck {
+		return 0, id, nil, false
+	}
+	copy(id[:], data[5:envelopeHeader])
+	return kind, id, data[envelopeHeader:], true
+}
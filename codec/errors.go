@@ -6,4 +6,15 @@ var (
 	ErrType    = errors.New("codec: parse type error")
 	ErrLength  = errors.New("codec: data length mismatch")
 	ErrUnknown = errors.New("codec: unknown error")
+
+	ErrFrameTooLarge   = errors.New("codec: frame exceeds max frame size")
+	ErrMalformedHeader = errors.New("codec: malformed frame header")
+
+	ErrShortData      = errors.New("codec: data too short")
+	ErrInvalidReceipt = errors.New("codec: invalid receipt id")
+
+	ErrInvalidScheme        = errors.New("codec: invalid scheme")
+	ErrInvalidURL           = errors.New("codec: invalid fernq url")
+	ErrInvalidNode          = errors.New("codec: invalid node address")
+	ErrInvalidVerifyMessage = errors.New("codec: invalid verify message")
 )
@@ -19,6 +19,9 @@ const (
 	TypeResponseMessage    FernqTypeCode = 0xA7 // 167 响应消息
 	TypeUserScanSingle     FernqTypeCode = 0xA8 // 168 扫描单播，随机选择一个
 	TypeRequestMessageScan FernqTypeCode = 0xA9 // 169 请求消息扫描,随机选择一个发送
+	TypeRequestMessageAll  FernqTypeCode = 0xAA // 170 请求消息扫描,发送给全部匹配的用户
+	TypeConfirmSend        FernqTypeCode = 0xAB // 171 确认发送，服务器投递后返回回执
+	TypeDeliveryReceipt    FernqTypeCode = 0xAC // 172 投递回执
 )
 
 const (
@@ -42,3 +45,46 @@ const (
 	StatusBadGateway          StatusCode = 502
 	StatusServiceUnavailable  StatusCode = 503
 )
+
+// DeliveryKind 接收消息的投递方式，对应 ReceiveMessage.kind
+type DeliveryKind int32
+
+const (
+	DeliveryUnknown    DeliveryKind = 0 // 未知，服务器未提供投递方式
+	DeliveryDirect     DeliveryKind = 1 // P2P中转
+	DeliveryBroadcast  DeliveryKind = 2 // 房间广播
+	DeliveryScan       DeliveryKind = 3 // 扫描组播
+	DeliveryScanSingle DeliveryKind = 4 // 扫描单播
+)
+
+// String 返回投递方式名称
+func (k DeliveryKind) String() string {
+	switch k {
+	case DeliveryDirect:
+		return "Direct"
+	case DeliveryBroadcast:
+		return "Broadcast"
+	case DeliveryScan:
+		return "Scan"
+	case DeliveryScanSingle:
+		return "ScanSingle"
+	default:
+		return "Unknown"
+	}
+}
+
+// DeliveryKindOf 返回发送帧类型对应的投递方式
+func DeliveryKindOf(msgType FernqTypeCode) DeliveryKind {
+	switch msgType {
+	case TypeP2PRelay:
+		return DeliveryDirect
+	case TypeRoomBroadcast:
+		return DeliveryBroadcast
+	case TypeUserScan:
+		return DeliveryScan
+	case TypeUserScanSingle:
+		return DeliveryScanSingle
+	default:
+		return DeliveryUnknown
+	}
+}
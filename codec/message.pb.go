@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.36.10
+// 	protoc-gen-go v1.36.11
 // 	protoc        v5.29.3
 // source: message.proto
 
@@ -132,6 +132,8 @@ type ReceiveMessage struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	From          string                 `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
 	Message       []byte                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Kind          int32                  `protobuf:"varint,3,opt,name=kind,proto3" json:"kind,omitempty"`    // 投递方式，见 DeliveryKind
+	Target        string                 `protobuf:"bytes,4,opt,name=target,proto3" json:"target,omitempty"` // 发送方指定的目标，如广播房间名、扫描正则
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -180,6 +182,20 @@ func (x *ReceiveMessage) GetMessage() []byte {
 	return nil
 }
 
+func (x *ReceiveMessage) GetKind() int32 {
+	if x != nil {
+		return x.Kind
+	}
+	return 0
+}
+
+func (x *ReceiveMessage) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
 // 请求体消息
 type RequestBody struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -286,6 +302,136 @@ func (x *ResponseBody) GetBody() []byte {
 	return nil
 }
 
+// 确认发送消息
+type ConfirmMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            []byte                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`           // 回执id
+	Kind          int32                  `protobuf:"varint,2,opt,name=kind,proto3" json:"kind,omitempty"`      // 投递方式，见 DeliveryKind
+	Target        string                 `protobuf:"bytes,3,opt,name=target,proto3" json:"target,omitempty"`   // 目标名称或扫描正则
+	Message       []byte                 `protobuf:"bytes,4,opt,name=message,proto3" json:"message,omitempty"` // 消息内容
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConfirmMessage) Reset() {
+	*x = ConfirmMessage{}
+	mi := &file_message_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConfirmMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfirmMessage) ProtoMessage() {}
+
+func (x *ConfirmMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfirmMessage.ProtoReflect.Descriptor instead.
+func (*ConfirmMessage) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConfirmMessage) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *ConfirmMessage) GetKind() int32 {
+	if x != nil {
+		return x.Kind
+	}
+	return 0
+}
+
+func (x *ConfirmMessage) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *ConfirmMessage) GetMessage() []byte {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+// 投递回执
+type DeliveryReceipt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            []byte                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`                  // 回执id
+	Status        int32                  `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`         // 投递结果状态码
+	Recipients    int32                  `protobuf:"varint,3,opt,name=recipients,proto3" json:"recipients,omitempty"` // 实际接收的客户端数
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeliveryReceipt) Reset() {
+	*x = DeliveryReceipt{}
+	mi := &file_message_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeliveryReceipt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeliveryReceipt) ProtoMessage() {}
+
+func (x *DeliveryReceipt) ProtoReflect() protoreflect.Message {
+	mi := &file_message_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeliveryReceipt.ProtoReflect.Descriptor instead.
+func (*DeliveryReceipt) Descriptor() ([]byte, []int) {
+	return file_message_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeliveryReceipt) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *DeliveryReceipt) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *DeliveryReceipt) GetRecipients() int32 {
+	if x != nil {
+		return x.Recipients
+	}
+	return 0
+}
+
 var File_message_proto protoreflect.FileDescriptor
 
 const file_message_proto_rawDesc = "" +
@@ -296,16 +442,29 @@ const file_message_proto_rawDesc = "" +
 	"\x05token\x18\x02 \x01(\tR\x05token\"B\n" +
 	"\x0eTransitMessage\x12\x16\n" +
 	"\x06target\x18\x01 \x01(\tR\x06target\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\fR\amessage\">\n" +
+	"\amessage\x18\x02 \x01(\fR\amessage\"j\n" +
 	"\x0eReceiveMessage\x12\x12\n" +
 	"\x04from\x18\x01 \x01(\tR\x04from\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\fR\amessage\"3\n" +
+	"\amessage\x18\x02 \x01(\fR\amessage\x12\x12\n" +
+	"\x04kind\x18\x03 \x01(\x05R\x04kind\x12\x16\n" +
+	"\x06target\x18\x04 \x01(\tR\x06target\"3\n" +
 	"\vRequestBody\x12\x10\n" +
 	"\x03url\x18\x01 \x01(\tR\x03url\x12\x12\n" +
 	"\x04body\x18\x02 \x01(\fR\x04body\":\n" +
 	"\fResponseBody\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\x05R\x06status\x12\x12\n" +
-	"\x04body\x18\x02 \x01(\fR\x04bodyB)Z'github.com/xfs0205/fernq/internal/codecb\x06proto3"
+	"\x04body\x18\x02 \x01(\fR\x04body\"f\n" +
+	"\x0eConfirmMessage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\fR\x02id\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\x05R\x04kind\x12\x16\n" +
+	"\x06target\x18\x03 \x01(\tR\x06target\x12\x18\n" +
+	"\amessage\x18\x04 \x01(\fR\amessage\"Y\n" +
+	"\x0fDeliveryReceipt\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\fR\x02id\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\x05R\x06status\x12\x1e\n" +
+	"\n" +
+	"recipients\x18\x03 \x01(\x05R\n" +
+	"recipientsB)Z'github.com/xfs0205/fernq/internal/codecb\x06proto3"
 
 var (
 	file_message_proto_rawDescOnce sync.Once
@@ -319,13 +478,15 @@ func file_message_proto_rawDescGZIP() []byte {
 	return file_message_proto_rawDescData
 }
 
-var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_message_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_message_proto_goTypes = []any{
-	(*VerifyMessage)(nil),  // 0: codec.VerifyMessage
-	(*TransitMessage)(nil), // 1: codec.TransitMessage
-	(*ReceiveMessage)(nil), // 2: codec.ReceiveMessage
-	(*RequestBody)(nil),    // 3: codec.RequestBody
-	(*ResponseBody)(nil),   // 4: codec.ResponseBody
+	(*VerifyMessage)(nil),   // 0: codec.VerifyMessage
+	(*TransitMessage)(nil),  // 1: codec.TransitMessage
+	(*ReceiveMessage)(nil),  // 2: codec.ReceiveMessage
+	(*RequestBody)(nil),     // 3: codec.RequestBody
+	(*ResponseBody)(nil),    // 4: codec.ResponseBody
+	(*ConfirmMessage)(nil),  // 5: codec.ConfirmMessage
+	(*DeliveryReceipt)(nil), // 6: codec.DeliveryReceipt
 }
 var file_message_proto_depIdxs = []int32{
 	0, // [0:0] is the sub-list for method output_type
@@ -346,7 +507,7 @@ func file_message_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_message_proto_rawDesc), len(file_message_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   5,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -61,3 +61,27 @@ func DecodeResponseBodyPB(b []byte) (*ResponseBody, error) {
 	}
 	return &rb, nil
 }
+
+// ========== ConfirmMessage ==========
+func EncodeConfirmMessagePB(cm *ConfirmMessage) ([]byte, error) {
+	return proto.Marshal(cm)
+}
+func DecodeConfirmMessagePB(b []byte) (*ConfirmMessage, error) {
+	var cm ConfirmMessage
+	if err := proto.Unmarshal(b, &cm); err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
+
+// ========== DeliveryReceipt ==========
+func EncodeDeliveryReceiptPB(dr *DeliveryReceipt) ([]byte, error) {
+	return proto.Marshal(dr)
+}
+func DecodeDeliveryReceiptPB(b []byte) (*DeliveryReceipt, error) {
+	var dr DeliveryReceipt
+	if err := proto.Unmarshal(b, &dr); err != nil {
+		return nil, err
+	}
+	return &dr, nil
+}
@@ -12,6 +12,39 @@ import (
 
 // ====================== 注册连接 ======================
 
+// 连接地址支持的协议
+const (
+	SchemeFernq    = "fernq"     // 明文 TCP
+	SchemeFernqs   = "fernqs"    // TLS 加密 TCP
+	SchemeFernqWS  = "fernq+ws"  // WebSocket
+	SchemeFernqWSS = "fernq+wss" // TLS 加密的 WebSocket
+)
+
+// 节点的默认端口
+const DefaultPort = "9147"
+
+// WebSocket 传输默认的请求路径
+const DefaultWebSocketPath = "/fernq"
+
+// SplitScheme 拆分连接地址的协议
+// 输入: "fernqs://connect/node-a.local:8080/uuid#room?room_pass=secret"
+// 输出: ("fernqs", "fernq://connect/node-a.local:8080/uuid#room?room_pass=secret", nil)
+//
+// 返回的地址统一为 fernq:// 协议，用作验证消息中的 Token，服务端无需感知传输方式
+func SplitScheme(roomURL string) (scheme string, plainURL string, err error) {
+	idx := strings.Index(roomURL, "://")
+	if idx == -1 {
+		return "", "", fmt.Errorf("%w: missing ://", ErrInvalidScheme)
+	}
+	scheme = roomURL[:idx]
+	switch scheme {
+	case SchemeFernq, SchemeFernqs, SchemeFernqWS, SchemeFernqWSS:
+	default:
+		return "", "", fmt.Errorf("%w: unsupported scheme '%s'", ErrInvalidScheme, scheme)
+	}
+	return scheme, SchemeFernq + roomURL[idx:], nil
+}
+
 // ValidateAndExtractAddress 验证URL格式，返回可直接连接的地址
 // 输入参数:
 //   - username: 用户名（如 "alice"）
@@ -22,32 +55,89 @@ import (
 //	("node-a.local", []byte(encoded), nil)        域名无端口
 //	("192.168.1.100:9147", []byte(encoded), nil)  IP无端口时用默认9147
 //	("[::1]:9147", []byte(encoded), nil)          IPv6无端口时用默认9147
+//
+// 同时接受 SplitScheme 支持的其他协议，验证消息中的 Token 统一为 fernq:// 协议；
+// 列出多个节点时返回第一个，获取全部节点请使用 ValidateAndExtractAddresses
 func ValidateAndExtractAddress(username string, roomURL string) (address string, raw []byte, err error) {
+	addresses, raw, err := ValidateAndExtractAddresses(username, roomURL)
+	if err != nil {
+		return "", nil, err
+	}
+	return addresses[0], raw, nil
+}
+
+// ValidateAndExtractAddresses 验证URL格式，返回全部节点的可直接连接的地址
+// 输入参数:
+//   - username: 用户名（如 "alice"）
+//   - roomURL: 目标URL，节点之间用逗号分隔（如 "fernq://connect/a.local:9147,b.local:9147/uuid#room"）
+//
+// 输出: ([]string{"a.local:9147", "b.local:9147"}, []byte(encoded), nil)
+//
+// 每个节点的端口规则与 ValidateAndExtractAddress 相同，所有节点共用同一个验证消息
+func ValidateAndExtractAddresses(username string, roomURL string) (addresses []string, raw []byte, err error) {
+	// 0. 统一协议
+	if _, plainURL, err := SplitScheme(roomURL); err == nil {
+		roomURL = plainURL
+	}
+
 	// 1. 基础检查
 	if !strings.HasPrefix(roomURL, "fernq://connect/") {
-		return "", nil, fmt.Errorf("invalid scheme: must start with fernq://connect/")
+		return nil, nil, fmt.Errorf("%w: must start with fernq://connect/", ErrInvalidScheme)
 	}
 
 	// 2. 标准URL解析
 	u, err := url.Parse(roomURL)
 	if err != nil {
-		return "", nil, fmt.Errorf("invalid url format: %w", err)
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
 	}
 
 	// 3. 验证 host 必须是 "connect"
 	if u.Host != "connect" {
-		return "", nil, fmt.Errorf("invalid host: expected 'connect', got '%s'", u.Host)
+		return nil, nil, fmt.Errorf("%w: expected host 'connect', got '%s'", ErrInvalidURL, u.Host)
 	}
 
-	// 4. 提取节点地址（path 的第一段，可能包含端口）
+	// 4. 提取节点地址（path 的第一段，可能包含端口，多个节点用逗号分隔）
 	path := strings.TrimPrefix(u.Path, "/")
 	pathParts := strings.SplitN(path, "/", 2)
 	if len(pathParts) < 2 || pathParts[0] == "" {
-		return "", nil, fmt.Errorf("missing node address")
+		return nil, nil, fmt.Errorf("%w: missing node address", ErrInvalidNode)
 	}
-	nodePart := pathParts[0]
 
-	// 5. 解析节点地址和端口
+	// 5. 逐个解析节点地址和端口
+	for _, nodePart := range strings.Split(pathParts[0], ",") {
+		if nodePart == "" {
+			return nil, nil, fmt.Errorf("%w: missing node address", ErrInvalidNode)
+		}
+		address, err := extractNodeAddress(nodePart)
+		if err != nil {
+			return nil, nil, err
+		}
+		addresses = append(addresses, address)
+	}
+
+	// 6. 构造 VerifyMessage
+	vm := &VerifyMessage{
+		ClientId: username,
+		Token:    roomURL,
+	}
+
+	// 7. protobuf 编码
+	vmData, err := EncodeVerifyMessagePB(vm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode verify message: %w", err)
+	}
+
+	// 8. 外层封装编码
+	raw, err = Encode(TypeRoomVerify, vmData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return addresses, raw, nil
+}
+
+// extractNodeAddress 解析单个节点地址和端口，返回可直接连接的地址
+func extractNodeAddress(nodePart string) (address string, err error) {
 	var node string
 	var port string
 	var isIP bool
@@ -62,7 +152,7 @@ func ValidateAndExtractAddress(username string, roomURL string) (address string,
 			node = nodePart
 			port = ""
 		} else {
-			return "", nil, fmt.Errorf("invalid IPv6 format: %s", nodePart)
+			return "", fmt.Errorf("%w: invalid IPv6 format: %s", ErrInvalidNode, nodePart)
 		}
 		isIP = true
 	} else {
@@ -79,15 +169,15 @@ func ValidateAndExtractAddress(username string, roomURL string) (address string,
 		isIP = net.ParseIP(node) != nil
 	}
 
-	// 6. 验证节点地址格式（IP或域名）
+	// 验证节点地址格式（IP或域名）
 	if !isValidHost(node) {
-		return "", nil, fmt.Errorf("invalid node format: %s", node)
+		return "", fmt.Errorf("%w: %s", ErrInvalidNode, node)
 	}
 
-	// 7. 组装地址
+	// 组装地址
 	// IP 没有端口时默认 9147，域名保持原样
 	if isIP && port == "" {
-		port = "9147"
+		port = DefaultPort
 	}
 
 	if port != "" {
@@ -101,26 +191,7 @@ func ValidateAndExtractAddress(username string, roomURL string) (address string,
 		// 域名无端口
 		address = node
 	}
-
-	// 8. 构造 VerifyMessage
-	vm := &VerifyMessage{
-		ClientId: username,
-		Token:    roomURL,
-	}
-
-	// 9. protobuf 编码
-	vmData, err := EncodeVerifyMessagePB(vm)
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to encode verify message: %w", err)
-	}
-
-	// 10. 外层封装编码
-	raw, err = Encode(TypeRoomVerify, vmData)
-	if err != nil {
-		return "", nil, err
-	}
-
-	return address, raw, nil
+	return address, nil
 }
 
 // isValidHost 验证host是有效IP或域名
@@ -179,13 +250,13 @@ func ValidateAndExtractInfo(data []byte) (RoomInfo, error) {
 	// 0. 解析 VerifyMessage
 	vm, err := DecodeVerifyMessagePB(data)
 	if err != nil {
-		return info, fmt.Errorf("failed to decode verify message: %w", err)
+		return info, fmt.Errorf("%w: %w", ErrInvalidVerifyMessage, err)
 	}
 
 	// 1. 提取 Username（来自 ClientId）
 	info.Username = vm.ClientId
 	if info.Username == "" {
-		return info, fmt.Errorf("missing client_id in verify message")
+		return info, fmt.Errorf("%w: missing client_id", ErrInvalidVerifyMessage)
 	}
 
 	// 2. 从 VerifyMessage.Token 获取目标 URL
@@ -193,7 +264,7 @@ func ValidateAndExtractInfo(data []byte) (RoomInfo, error) {
 
 	// 3. 基础检查
 	if !strings.HasPrefix(roomURL, "fernq://connect/") {
-		return info, fmt.Errorf("invalid scheme: must start with fernq://connect/")
+		return info, fmt.Errorf("%w: must start with fernq://connect/", ErrInvalidScheme)
 	}
 
 	// 4. 去掉前缀，手动解析
@@ -201,7 +272,7 @@ func ValidateAndExtractInfo(data []byte) (RoomInfo, error) {
 
 	// 5. 跳过节点地址（node-a.local/）
 	if slashIdx := strings.Index(rest, "/"); slashIdx == -1 {
-		return info, fmt.Errorf("missing path separator after node")
+		return info, fmt.Errorf("%w: missing path separator after node", ErrInvalidURL)
 	} else {
 		rest = rest[slashIdx+1:] // 剩下: uuid#room_name?params
 	}
@@ -223,7 +294,7 @@ func ValidateAndExtractInfo(data []byte) (RoomInfo, error) {
 
 	// 7. 提取UUID和房间名（#分割）
 	if hashIdx := strings.Index(rest, "#"); hashIdx == -1 {
-		return info, fmt.Errorf("missing room name separator #")
+		return info, fmt.Errorf("%w: missing room name separator #", ErrInvalidURL)
 	} else {
 		info.UUID = rest[:hashIdx]
 		info.RoomName = rest[hashIdx+1:]
@@ -231,10 +302,10 @@ func ValidateAndExtractInfo(data []byte) (RoomInfo, error) {
 
 	// 8. 验证必填字段
 	if info.UUID == "" {
-		return info, fmt.Errorf("missing uuid")
+		return info, fmt.Errorf("%w: missing uuid", ErrInvalidURL)
 	}
 	if info.RoomName == "" {
-		return info, fmt.Errorf("missing room name")
+		return info, fmt.Errorf("%w: missing room name", ErrInvalidURL)
 	}
 
 	// 9. URL解码房间名
@@ -296,54 +367,22 @@ func ParseRoomVerifyRes(data []byte) (bool, string, error) {
 
 // 创建房间广播
 func CreateRoomBroadcast(room string, message []byte) ([]byte, error) {
-	mes := &TransitMessage{
-		Target:  room,
-		Message: message,
-	}
-	mesByte, err := EncodeTransitMessagePB(mes) // 中转消息
-	if err != nil {
-		return nil, err
-	}
-	return Encode(TypeRoomBroadcast, mesByte)
+	return AppendRoomBroadcast(nil, room, message), nil
 }
 
 // 创建扫描组播
 func CreateUserScan(scan string, message []byte) ([]byte, error) {
-	mes := &TransitMessage{
-		Target:  scan,
-		Message: message,
-	}
-	mesByte, err := EncodeTransitMessagePB(mes) // 中转消息
-	if err != nil {
-		return nil, err
-	}
-	return Encode(TypeUserScan, mesByte)
+	return AppendUserScan(nil, scan, message), nil
 }
 
 // 创建扫描单播
 func CreateUserScanSingle(scan string, message []byte) ([]byte, error) {
-	mes := &TransitMessage{
-		Target:  scan,
-		Message: message,
-	}
-	mesByte, err := EncodeTransitMessagePB(mes) // 中转消息
-	if err != nil {
-		return nil, err
-	}
-	return Encode(TypeUserScanSingle, mesByte)
+	return AppendUserScanSingle(nil, scan, message), nil
 }
 
 // 创建P2P中转
 func CreateP2PRelay(target string, message []byte) ([]byte, error) {
-	mes := &TransitMessage{
-		Target:  target,
-		Message: message,
-	}
-	mesByte, err := EncodeTransitMessagePB(mes) // 中转消息
-	if err != nil {
-		return nil, err
-	}
-	return Encode(TypeP2PRelay, mesByte)
+	return AppendP2PRelay(nil, target, message), nil
 }
 
 // 创建接收消息
@@ -352,23 +391,35 @@ func CreateReceiveMessage(from string, message []byte) ([]byte, error) {
 		From:    from,
 		Message: message,
 	}
-	mesByte, err := EncodeReceiveMessagePB(mes) // 接收消息
-	if err != nil {
-		return nil, err
+	return AppendMessage(nil, TypeReceiveMessage, mes) // 接收消息
+}
+
+// 创建带投递信息的接收消息，服务端使用
+// 参数:
+//   - from: 发送方名称
+//   - kind: 投递方式，通常由 DeliveryKindOf(收到的帧类型) 得到
+//   - target: 发送方指定的目标，如广播房间名、扫描正则
+//   - message: 消息内容
+func CreateReceiveMessageKind(from string, kind DeliveryKind, target string, message []byte) ([]byte, error) {
+	mes := &ReceiveMessage{
+		From:    from,
+		Message: message,
+		Kind:    int32(kind),
+		Target:  target,
 	}
-	return Encode(TypeReceiveMessage, mesByte)
+	return AppendMessage(nil, TypeReceiveMessage, mes) // 接收消息
 }
 
 // ======================= 活性测试 =======================
 
 // 创建心跳消息
 func CreatePing() ([]byte, error) {
-	return Encode(TypePing, nil)
+	return AppendPing(nil), nil
 }
 
 // 创建心跳响应
 func CreatePong() ([]byte, error) {
-	return Encode(TypePong, nil)
+	return AppendPong(nil), nil
 }
 
 // ====================== 请求响应 ======================
@@ -430,7 +481,38 @@ func CreateRequestMessageScan(scan string, url string, body []byte) (string, []b
 	if err != nil {
 		return "", nil, err
 	}
-	result, err := Encode(TypeRequestMessage, mesByte)
+	result, err := Encode(TypeRequestMessageScan, mesByte)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return xxuuid.String(), result, nil
+}
+
+// 客户端使用
+// 创建扫描全部匹配用户的请求体的中转消息，所有匹配用户的响应共用同一个请求id
+func CreateRequestMessageAll(scan string, url string, body []byte) (string, []byte, error) {
+	// 生成请求体的uuid的[]byte数组
+	xxuuid := uuid.New()
+
+	// 生成请求消息
+	message, err := EncodeRequestBodyPB(&RequestBody{
+		Url:  url,
+		Body: body,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	// 封装为中转消息
+	mes := &TransitMessage{
+		Target:  scan,
+		Message: append(xxuuid[:], message...), // 添加uuid
+	}
+	mesByte, err := EncodeTransitMessagePB(mes) // 中转消息
+	if err != nil {
+		return "", nil, err
+	}
+	result, err := Encode(TypeRequestMessageAll, mesByte)
 	if err != nil {
 		return "", nil, err
 	}
@@ -446,6 +528,9 @@ func CreateResponseMessage(target string, xxuuid, body []byte, status StatusCode
 		Status: int32(status),
 		Body:   body,
 	})
+	if err != nil {
+		return nil, err
+	}
 	// 封装为中转消息
 	mes := &TransitMessage{
 		Target:  target,
@@ -490,7 +575,7 @@ func CreateResponseReceiveMessage(from string, message []byte) ([]byte, error) {
 // 解析请求或响应,获取id
 func ParseRequestOrResponseId(data []byte) ([]byte, error) {
 	if len(data) < 16 {
-		return nil, fmt.Errorf("data too short")
+		return nil, ErrShortData
 	}
 
 	// 拷贝 16 字节，与原 data 脱钩
@@ -504,7 +589,7 @@ func ParseRequestOrResponseId(data []byte) ([]byte, error) {
 // 解析请求接收消息
 func ParseRequestReceiveMessage(data []byte) ([]byte, *RequestBody, error) {
 	if len(data) < 16 {
-		return nil, nil, fmt.Errorf("data too short")
+		return nil, nil, ErrShortData
 	}
 
 	// 拷贝 16 字节，与原 data 脱钩
@@ -523,7 +608,7 @@ func ParseRequestReceiveMessage(data []byte) ([]byte, *RequestBody, error) {
 // 解析响应接收消息
 func ParseResponseReceiveMessage(data []byte) (string, *ResponseBody, error) {
 	if len(data) < 16 {
-		return "", nil, fmt.Errorf("data too short")
+		return "", nil, ErrShortData
 	}
 
 	// 1. 取出并拷贝 16 字节
@@ -545,3 +630,57 @@ func ParseResponseReceiveMessage(data []byte) (string, *ResponseBody, error) {
 	// 4. 返回标准字符串形式，与 CreateRequestMessage 里的一致
 	return uid.String(), mes, nil
 }
+
+// ====================== 确认发送 ======================
+
+// 客户端使用
+// 创建确认发送消息，返回回执id和消息
+//
+// 参数:
+//   - kind: 投递方式，DeliveryDirect、DeliveryBroadcast、DeliveryScan 或 DeliveryScanSingle
+//   - target: 目标名称、广播房间名或扫描正则
+//   - message: 消息内容
+func CreateConfirmSend(kind DeliveryKind, target string, message []byte) (string, []byte, error) {
+	xxuuid := uuid.New()
+	mes := &ConfirmMessage{
+		Id:      xxuuid[:],
+		Kind:    int32(kind),
+		Target:  target,
+		Message: message,
+	}
+	result, err := AppendMessage(nil, TypeConfirmSend, mes)
+	if err != nil {
+		return "", nil, err
+	}
+	return xxuuid.String(), result, nil
+}
+
+// 服务器使用
+// 创建投递回执
+//
+// 参数:
+//   - id: 确认发送消息中的回执id
+//   - status: 投递结果，StatusOK 表示至少投递给一个客户端，StatusNotFound 表示没有匹配的客户端
+//   - recipients: 实际接收的客户端数
+func CreateDeliveryReceipt(id []byte, status StatusCode, recipients int) ([]byte, error) {
+	mes := &DeliveryReceipt{
+		Id:         id,
+		Status:     int32(status),
+		Recipients: int32(recipients),
+	}
+	return AppendMessage(nil, TypeDeliveryReceipt, mes)
+}
+
+// 客户端使用
+// 解析投递回执，返回回执id的标准字符串形式
+func ParseDeliveryReceipt(data []byte) (string, *DeliveryReceipt, error) {
+	mes, err := DecodeDeliveryReceiptPB(data)
+	if err != nil {
+		return "", nil, err
+	}
+	uid, err := uuid.FromBytes(mes.Id)
+	if err != nil {
+		return "", nil, ErrInvalidReceipt
+	}
+	return uid.String(), mes, nil
+}
@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// 数据不足 16 字节的请求id时返回 ErrShortData，与帧长度错误区分
+func TestParseShortData(t *testing.T) {
+	short := make([]byte, 15)
+	if _, err := ParseRequestOrResponseId(short); !errors.Is(err, ErrShortData) {
+		t.Errorf("ParseRequestOrResponseId: %v", err)
+	}
+	if _, _, err := ParseRequestReceiveMessage(short); !errors.Is(err, ErrShortData) {
+		t.Errorf("ParseRequestReceiveMessage: %v", err)
+	}
+	if _, _, err := ParseResponseReceiveMessage(short); !errors.Is(err, ErrShortData) {
+		t.Errorf("ParseResponseReceiveMessage: %v", err)
+	}
+}
+
+// 回执id不是合法的 uuid 时返回 ErrInvalidReceipt
+func TestParseDeliveryReceipt(t *testing.T) {
+	id := uuid.New()
+	frame, err := CreateDeliveryReceipt(id[:], StatusOK, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, receipt, err := ParseDeliveryReceipt(frame[HeaderTotal:])
+	if err != nil || got != id.String() || receipt.Recipients != 2 {
+		t.Fatalf("ParseDeliveryReceipt = %s %v %v", got, receipt, err)
+	}
+
+	body, err := EncodeDeliveryReceiptPB(&DeliveryReceipt{Id: []byte{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ParseDeliveryReceipt(body); !errors.Is(err, ErrInvalidReceipt) || errors.Is(err, ErrLength) {
+		t.Fatalf("无效的回执id返回 %v，期望 ErrInvalidReceipt", err)
+	}
+}
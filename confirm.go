@@ -0,0 +1,113 @@
+package fernqclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 投递回执
+type deliveryReceipt struct {
+	status     codec.StatusCode
+	recipients int
+}
+
+// SendConfirmed 确认发送模式的 P2P 发送，等待服务器返回投递回执
+// 参数:
+//   - ctx: 上下文，用于取消等待或设置超时
+//   - to: 目标客户端名称
+//   - message: 消息内容
+//
+// 返回值:
+//   - int: 实际接收消息的客户端数
+//   - error: 目标不存在时返回 *StatusError，Code 为 codec.StatusNotFound；
+//     以及发送失败、上下文取消或超时、连接断开 ErrConnectionLost
+//
+// 注意事项:
+//   - 回执表示服务器已将消息交给目标连接，不代表对方已处理
+//   - 需要服务器支持 TypeConfirmSend（fernqd、fernqtest），不支持的服务器不会返回回执，调用会等到 ctx 结束
+//
+// 使用方式:
+//
+//	_, err := client.SendConfirmed(ctx, "target-client", []byte("hello"))
+//	var se *fernqclient.StatusError
+//	if errors.As(err, &se) && se.Code == codec.StatusNotFound {
+//	    // 目标不在线
+//	}
+func (c *Client) SendConfirmed(ctx context.Context, to string, message []byte) (int, error) {
+	return c.sendConfirmed(ctx, codec.DeliveryDirect, to, message)
+}
+
+// ScanSendConfirmed 确认发送模式的扫描发送，返回匹配并收到消息的客户端数
+//
+// 没有匹配的客户端时返回 *StatusError，Code 为 codec.StatusNotFound，其余说明见 SendConfirmed。
+func (c *Client) ScanSendConfirmed(ctx context.Context, pattern string, message []byte) (int, error) {
+	// 验证正则表达式有效性
+	if _, err := regexp.Compile(pattern); err != nil {
+		return 0, fmt.Errorf("无效的正则表达式 '%s': %w", pattern, err)
+	}
+	return c.sendConfirmed(ctx, codec.DeliveryScan, pattern, message)
+}
+
+// 发送确认消息并等待回执
+func (c *Client) sendConfirmed(ctx context.Context, kind codec.DeliveryKind, target string, message []byte) (int, error) {
+	id, data, err := codec.CreateConfirmSend(kind, target, message)
+	if err != nil {
+		return 0, fmt.Errorf("创建确认发送消息失败: %w", err)
+	}
+
+	ch := make(chan deliveryReceipt, 1)
+	c.pendingMu.Lock()
+	if c.receipts == nil {
+		c.receipts = make(map[string]chan deliveryReceipt)
+	}
+	c.receipts[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.receipts, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.safeWrite(data); err != nil {
+		return 0, err
+	}
+
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			return 0, ErrConnectionLost
+		}
+		if r.status < 200 || r.status >= 300 {
+			return r.recipients, &StatusError{From: target, Code: r.status}
+		}
+		return r.recipients, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// 将投递回执交给等待中的发送方
+func (c *Client) dispatchReceipt(body []byte) {
+	id, receipt, err := codec.ParseDeliveryReceipt(body)
+	if err != nil {
+		log.Println("解析投递回执失败")
+		return
+	}
+
+	c.pendingMu.Lock()
+	ch, ok := c.receipts[id]
+	delete(c.receipts, id)
+	c.pendingMu.Unlock()
+	if !ok {
+		// 发送方已超时或已取消，丢弃回执
+		return
+	}
+	ch <- deliveryReceipt{
+		status:     codec.StatusCode(receipt.Status),
+		recipients: int(receipt.Recipients),
+	}
+}
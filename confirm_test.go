@@ -0,0 +1,33 @@
+package fernqclient_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 确认发送：目标在线时返回投递数，目标不存在时返回 404
+func TestSendConfirmed(t *testing.T) {
+	s := newServer(t)
+	sender := connect(t, s, "sender")
+	recv := connect(t, s, "recv")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	n, err := sender.SendConfirmed(ctx, "recv", []byte("hello"))
+	if err != nil || n != 1 {
+		t.Fatalf("SendConfirmed 返回 %d, %v，期望 1, nil", n, err)
+	}
+	if msg := receive(t, recv); string(msg.Message) != "hello" {
+		t.Fatalf("收到 %q，期望 hello", msg.Message)
+	}
+
+	_, err = sender.SendConfirmed(ctx, "nobody", []byte("hello"))
+	var se *fernqclient.StatusError
+	if !errors.As(err, &se) || se.Code != codec.StatusNotFound {
+		t.Fatalf("错误为 %v，期望 404 的 *StatusError", err)
+	}
+}
@@ -0,0 +1,193 @@
+package fernqclient
+
+import (
+	"context"
+	"hash/maphash"
+	"log"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 默认的消息回调协程数
+const DefaultDispatchWorkers = 4
+
+// MessageHandler 消息回调函数
+type MessageHandler func(ctx context.Context, msg FernqMessage)
+
+// WithDispatchWorkers 设置执行消息回调的协程数
+//
+// 参数:
+//   - n: 协程数，<= 0 时使用 DefaultDispatchWorkers
+//   - ordered: 为 true 时同一发送方的消息固定由同一协程按到达顺序串行处理
+func WithDispatchWorkers(n int, ordered bool) Option {
+	return func(o *options) {
+		o.dispatchWorkers = n
+		o.dispatchOrdered = ordered
+	}
+}
+
+// OnMessage 注册消息回调，收到的消息交给回调处理而不再发送到 Read() 通道
+// 参数:
+//   - fn: 回调函数，传入 nil 表示注销
+//
+// 说明:
+//   - 回调在固定数量的协程中执行，协程数由 WithDispatchWorkers 配置
+//   - 回调队列已满时的处理方式与 Read() 通道相同，由 WithReadBuffer 配置
+//   - ctx 在调用 Stop() 后取消；回调发生 panic 时记录日志并继续处理后续消息
+//   - 按 OnMessageFrom、OnMessageKind、OnMessage 的顺序选择回调
+//
+// 使用方式:
+//
+//	client.OnMessage(func(ctx context.Context, msg fernqclient.FernqMessage) {
+//	    fmt.Printf("收到来自 %s 的消息: %s\n", msg.From, string(msg.Message))
+//	})
+func (c *Client) OnMessage(fn MessageHandler) {
+	c.messageHandlersMu.Lock()
+	defer c.messageHandlersMu.Unlock()
+	c.messageHandler = fn
+}
+
+// OnMessageFrom 注册指定发送方的消息回调
+// 参数:
+//   - from: 发送方的客户端名称，完全匹配
+//   - fn: 回调函数，传入 nil 表示注销
+func (c *Client) OnMessageFrom(from string, fn MessageHandler) {
+	c.messageHandlersMu.Lock()
+	defer c.messageHandlersMu.Unlock()
+	if fn == nil {
+		delete(c.fromHandlers, from)
+		return
+	}
+	if c.fromHandlers == nil {
+		c.fromHandlers = make(map[string]MessageHandler)
+	}
+	c.fromHandlers[from] = fn
+}
+
+// OnMessageKind 注册指定投递方式的消息回调，如单独处理广播消息
+// 参数:
+//   - kind: 投递方式，服务器未提供投递方式的消息为 codec.DeliveryUnknown
+//   - fn: 回调函数，传入 nil 表示注销
+func (c *Client) OnMessageKind(kind codec.DeliveryKind, fn MessageHandler) {
+	c.messageHandlersMu.Lock()
+	defer c.messageHandlersMu.Unlock()
+	if fn == nil {
+		delete(c.kindHandlers, kind)
+		return
+	}
+	if c.kindHandlers == nil {
+		c.kindHandlers = make(map[codec.DeliveryKind]MessageHandler)
+	}
+	c.kindHandlers[kind] = fn
+}
+
+// 查找消息对应的回调，未注册时返回 nil
+func (c *Client) lookupMessageHandler(msg *FernqMessage) MessageHandler {
+	c.messageHandlersMu.RLock()
+	defer c.messageHandlersMu.RUnlock()
+	if fn := c.fromHandlers[msg.From]; fn != nil {
+		return fn
+	}
+	if fn := c.kindHandlers[msg.Kind]; fn != nil {
+		return fn
+	}
+	return c.messageHandler
+}
+
+// 待执行的回调
+type dispatchItem struct {
+	fn   MessageHandler
+	msg  FernqMessage
+	done func(ok bool) // 回调结束后调用，ok 表示回调正常返回；未执行即丢弃时 ok 为 false
+}
+
+// 回调结束或被丢弃时通知
+func (it *dispatchItem) finish(ok bool) {
+	if it.done != nil {
+		it.done(ok)
+	}
+}
+
+// 回调协程池
+type dispatcher struct {
+	queues []chan dispatchItem // 有序模式下每个协程一个队列，否则所有协程共用一个队列
+	seed   maphash.Seed
+}
+
+// 创建回调协程池并启动协程，协程在队列关闭后退出
+func newDispatcher(ctx context.Context, o *options) *dispatcher {
+	n := o.dispatchWorkers
+	if n <= 0 {
+		n = DefaultDispatchWorkers
+	}
+	d := &dispatcher{seed: maphash.MakeSeed()}
+	if o.dispatchOrdered {
+		d.queues = make([]chan dispatchItem, n)
+		for i := range d.queues {
+			d.queues[i] = make(chan dispatchItem, o.readBufferSizeOrDefault())
+			go d.work(ctx, d.queues[i])
+		}
+	} else {
+		d.queues = []chan dispatchItem{make(chan dispatchItem, o.readBufferSizeOrDefault())}
+		for range n {
+			go d.work(ctx, d.queues[0])
+		}
+	}
+	return d
+}
+
+// 按发送方选择队列
+func (d *dispatcher) queue(from string) chan dispatchItem {
+	if len(d.queues) == 1 {
+		return d.queues[0]
+	}
+	return d.queues[maphash.String(d.seed, from)%uint64(len(d.queues))]
+}
+
+// 关闭所有队列，已入队的回调执行完毕后协程退出
+func (d *dispatcher) close() {
+	for _, q := range d.queues {
+		close(q)
+	}
+}
+
+// 回调协程
+func (d *dispatcher) work(ctx context.Context, q <-chan dispatchItem) {
+	for it := range q {
+		// 客户端停止后丢弃剩余的消息
+		if ctx.Err() != nil {
+			it.finish(false)
+			continue
+		}
+		it.finish(d.call(ctx, it))
+	}
+}
+
+// 执行回调，处理 panic，回调正常返回时返回 true
+func (d *dispatcher) call(ctx context.Context, it dispatchItem) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("处理来自 %s 的消息时发生panic: %v", it.msg.From, r)
+			ok = false
+		}
+	}()
+	it.fn(ctx, it.msg)
+	return true
+}
+
+// 将消息交给回调处理，未注册回调时发送到 Read() 通道
+// 返回消息是否被接收，按溢出策略丢弃时为 false
+//
+// done 不为 nil 时在消息处理结束后调用：回调在执行完毕后调用；
+// Read() 无法得知消费方何时处理完，进入通道即视为处理完成。
+// 消息未被接收时不调用 done。
+func (c *Client) route(closer *connCloser, msg FernqMessage, done func(ok bool)) (bool, error) {
+	if fn := c.lookupMessageHandler(&msg); fn != nil {
+		return deliver(c, closer, c.dispatcher.queue(msg.From), dispatchItem{fn: fn, msg: msg, done: done})
+	}
+	accepted, err := deliver(c, closer, c.readChan, msg)
+	if accepted && done != nil {
+		done(true)
+	}
+	return accepted, err
+}
@@ -0,0 +1,83 @@
+package fernqclient_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 有序模式下同一发送方的消息按发送顺序串行处理
+func TestDispatchOrdered(t *testing.T) {
+	const n = 50
+	senders := []string{"a", "b", "c"}
+
+	s := newServer(t)
+	recv := fernqclient.NewClient("recv", fernqclient.WithDispatchWorkers(4, true))
+	var (
+		mu     sync.Mutex
+		got    = make(map[string][]int)
+		active = make(map[string]int) // 每个发送方正在执行的回调数
+		done   = make(chan struct{})
+		total  int
+	)
+	recv.OnMessage(func(ctx context.Context, msg fernqclient.FernqMessage) {
+		mu.Lock()
+		active[msg.From]++
+		if active[msg.From] > 1 {
+			t.Errorf("%s 的消息被并发处理", msg.From)
+		}
+		mu.Unlock()
+
+		// 处理时间不同，无序时后到的消息可能先处理完
+		i, _ := strconv.Atoi(string(msg.Message))
+		time.Sleep(time.Duration(i%3) * time.Millisecond)
+
+		mu.Lock()
+		active[msg.From]--
+		got[msg.From] = append(got[msg.From], i)
+		total++
+		if total == n*len(senders) {
+			close(done)
+		}
+		mu.Unlock()
+	})
+	if err := recv.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer recv.Stop()
+
+	var wg sync.WaitGroup
+	for _, name := range senders {
+		sender := connect(t, s, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if err := sender.Send("recv", []byte(strconv.Itoa(i))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("等待回调处理完成超时")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range senders {
+		for i, v := range got[name] {
+			if v != i {
+				t.Fatalf("%s 的消息处理顺序为 %v", name, got[name])
+			}
+		}
+	}
+}
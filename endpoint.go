@@ -0,0 +1,195 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// EndpointOrder 多节点时的连接顺序
+type EndpointOrder int
+
+const (
+	EndpointOrderSequential EndpointOrder = iota // 按书写顺序依次尝试（默认）
+	EndpointOrderRandom                          // 随机顺序尝试，用于在多个节点间分散连接
+)
+
+// WithEndpointOrder 设置多节点时的连接顺序
+//
+// 无论哪种顺序，断线重连时都优先尝试当前节点，失败后再切换到其他节点。
+func WithEndpointOrder(order EndpointOrder) Option {
+	return func(o *options) {
+		o.endpointOrder = order
+	}
+}
+
+// 候选节点
+type endpoint struct {
+	scheme  string // 连接协议
+	address string // 节点地址
+	verify  []byte // 房间验证消息
+}
+
+// 解析连接地址，展开为候选节点列表
+func (c *Client) parseEndpoints(urls []string) ([]endpoint, error) {
+	if len(urls) == 0 {
+		return nil, &InvalidURLError{Err: errors.New("地址列表为空")}
+	}
+	var endpoints []endpoint
+	for _, FQC := range urls {
+		scheme, _, err := codec.SplitScheme(FQC)
+		if err != nil {
+			return nil, &InvalidURLError{URL: FQC, Err: err}
+		}
+		addresses, verify, err := codec.ValidateAndExtractAddresses(c.ClientName, FQC)
+		if err != nil {
+			return nil, &InvalidURLError{URL: FQC, Err: err}
+		}
+		for _, address := range addresses {
+			endpoints = append(endpoints, endpoint{
+				scheme:  scheme,
+				address: address,
+				verify:  verify,
+			})
+		}
+	}
+	return endpoints, nil
+}
+
+// Endpoint 返回当前（或最近一次）连接成功的节点地址，从未连接成功时返回空字符串
+//
+// 通过 SRV 记录解析的节点返回解析后的实际地址
+func (c *Client) Endpoint() string {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	return c.currentAddr
+}
+
+// ConnectURLs 连接服务器，依次尝试多个连接地址，任一节点验证成功即返回
+//
+// 参数:
+//   - ctx: 同 ConnectContext
+//   - urls: 服务器连接地址列表，每个地址的格式同 Connect，也可在单个地址中用逗号列出多个节点
+//   - opts: 同 ConnectContext
+//
+// 说明:
+//   - 某个节点拨号或房间验证失败时，自动切换到下一个节点
+//   - 顺序由 WithEndpointOrder 决定，当前节点可通过 Endpoint() 获取
+//   - 开启断线重连时，重连同样在所有节点间切换
+//
+// 使用方式:
+//
+//	err := client.ConnectURLs(ctx, []string{
+//	    "fernq://connect/a.example.com:9147/uuid#room?room_pass=secret",
+//	    "fernqs://connect/b.example.com:9148/uuid#room?room_pass=secret",
+//	})
+func (c *Client) ConnectURLs(ctx context.Context, urls []string, opts ...Option) error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	// 检查状态
+	c.statusMu.Lock()
+	if c.running {
+		c.statusMu.Unlock()
+		return ErrAlreadyConnected
+	}
+	c.statusMu.Unlock()
+
+	for _, opt := range opts {
+		opt(&c.opts)
+	}
+
+	endpoints, err := c.parseEndpoints(urls)
+	if err != nil {
+		return err
+	}
+	c.statusMu.Lock()
+	c.endpoints = endpoints
+	c.current = -1
+	c.currentAddr = ""
+	c.statusMu.Unlock()
+
+	conn, dec, err := c.connectEndpoints(ctx)
+	if err != nil {
+		c.setState(StateClosed, err)
+		return err
+	}
+
+	// 验证成功
+	// 添加上下文和取消函数
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	// 先创建发送队列和接收通道再进入已连接状态，状态回调中即可发送和读取
+	c.statusMu.Lock()
+	c.queue = newSendQueue(c.opts.sendQueueSize, c.opts.sendQueuePolicy, &c.stats.sendsDropped)
+	c.running = true
+	c.stopped = false
+	c.statusMu.Unlock()
+
+	// 添加读输入通道
+	c.readChan = make(chan FernqMessage, c.opts.readBufferSizeOrDefault())
+	c.requestSlots = make(chan struct{}, c.opts.maxConcurrentRequestsOrDefault())
+	c.dispatcher = newDispatcher(c.ctx, &c.opts)
+	c.attach(conn)
+
+	// 添加读协程
+	c.readLoop(dec)
+
+	// 发送发件箱中积压的消息
+	if c.opts.outbox != nil {
+		c.flushOutbox(c.opts.outbox)
+	}
+
+	return nil
+}
+
+// 按顺序尝试候选节点，验证成功时记录当前节点
+func (c *Client) connectEndpoints(ctx context.Context) (net.Conn, *codec.Decoder, error) {
+	var errs []error
+	for _, i := range c.endpointOrder() {
+		ep := c.endpoints[i]
+		for _, address := range c.resolveEndpoint(ctx, ep) {
+			conn, dec, err := c.handshake(ctx, ep, address)
+			if err == nil {
+				c.statusMu.Lock()
+				c.current = i
+				c.currentAddr = address
+				c.statusMu.Unlock()
+				return conn, dec, nil
+			}
+			if ctx.Err() != nil {
+				return nil, nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+		}
+	}
+	if len(errs) == 1 {
+		return nil, nil, errors.Unwrap(errs[0])
+	}
+	return nil, nil, errors.Join(errs...)
+}
+
+// 本次尝试的节点顺序，已连接过时从当前节点开始
+func (c *Client) endpointOrder() []int {
+	n := len(c.endpoints)
+	start := max(c.current, 0)
+	order := make([]int, 0, n)
+	for i := range n {
+		order = append(order, (start+i)%n)
+	}
+	if c.opts.endpointOrder == EndpointOrderRandom {
+		// 首次连接时全部打乱，重连时保留当前节点在首位
+		shuffle := order
+		if c.current >= 0 {
+			shuffle = order[1:]
+		}
+		rand.Shuffle(len(shuffle), func(i, j int) {
+			shuffle[i], shuffle[j] = shuffle[j], shuffle[i]
+		})
+	}
+	return order
+}
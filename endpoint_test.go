@@ -0,0 +1,71 @@
+package fernqclient_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 返回一个没有服务监听的本地地址
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// 第一个节点不可用时切换到下一个节点
+func TestConnectURLsFailover(t *testing.T) {
+	s := newServer(t)
+	dead := "fernq://connect/" + deadAddr(t) + "/uuid#test?room_pass=pass"
+
+	c := fernqclient.NewClient("edge")
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := c.ConnectURLs(ctx, []string{dead, s.RoomURL("uuid", "test", "pass")}); err != nil {
+		t.Fatalf("ConnectURLs: %v", err)
+	}
+	defer c.Stop()
+	if got := c.Endpoint(); got != s.Addr {
+		t.Fatalf("Endpoint() = %s，期望 %s", got, s.Addr)
+	}
+
+	peer := connect(t, s, "peer")
+	if err := peer.Send("edge", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, c); string(msg.Message) != "hello" {
+		t.Fatalf("收到 %q", msg.Message)
+	}
+}
+
+// 当前节点失效时断线重连切换到其他节点
+func TestReconnectFailover(t *testing.T) {
+	first := newServer(t)
+	second := newServer(t)
+
+	c := fernqclient.NewClient("edge", fernqclient.WithReconnect(fastReconnect()))
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	urls := []string{first.RoomURL("uuid", "test", "pass"), second.RoomURL("uuid", "test", "pass")}
+	if err := c.ConnectURLs(ctx, urls); err != nil {
+		t.Fatalf("ConnectURLs: %v", err)
+	}
+	defer c.Stop()
+	if got := c.Endpoint(); got != first.Addr {
+		t.Fatalf("Endpoint() = %s，期望 %s", got, first.Addr)
+	}
+
+	first.Close()
+	waitState(t, c, fernqclient.StateReconnecting)
+	waitState(t, c, fernqclient.StateConnected)
+	if got := c.Endpoint(); got != second.Addr {
+		t.Fatalf("重连后 Endpoint() = %s，期望 %s", got, second.Addr)
+	}
+}
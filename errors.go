@@ -0,0 +1,82 @@
+package fernqclient
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 可通过 errors.Is 判断的错误
+var (
+	ErrNotConnected     = errors.New("未连接")
+	ErrAlreadyConnected = errors.New("已连接")
+	ErrClosed           = errors.New("客户端已停止")   // 调用 Stop() 主动断开
+	ErrConnectionLost   = errors.New("连接已断开")    // 等待响应期间连接断开
+	ErrHandshakeTimeout = errors.New("验证超时")     // 等待房间验证结果超时
+	ErrSendQueueFull    = errors.New("发送队列已满")   // SendQueueFailFast 策略下发送队列已满
+	ErrReadBufferFull   = errors.New("接收通道已满")   // OverflowDisconnect 策略下接收通道已满导致断开
+	ErrIdleTimeout      = errors.New("读取空闲超时")   // 超过 WithReadIdleTimeout 未收到数据
+	ErrKeepaliveTimeout = errors.New("心跳超时")     // 连续多次未收到心跳响应
+	ErrReconnectLimit   = errors.New("超过最大重连次数") // 重连次数达到 ReconnectPolicy.MaxAttempts
+	ErrNotAcknowledged  = errors.New("未收到确认")    // 可靠投递达到最多发送次数仍未收到确认
+	ErrNoResponse       = errors.New("未收到任何响应")  // RequestAll 截止时间前没有任何客户端响应
+)
+
+// AuthError 房间验证失败，Msg 为服务器返回的原因
+type AuthError struct {
+	Msg string
+}
+
+func (e *AuthError) Error() string {
+	return "房间验证失败: " + e.Msg
+}
+
+// InvalidURLError 连接地址无效
+type InvalidURLError struct {
+	URL string // 无效的地址
+	Err error  // 具体原因，通常可用 errors.Is 匹配 codec 中的错误
+}
+
+func (e *InvalidURLError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("无效的FQC地址 '%s'", e.URL)
+	}
+	return fmt.Sprintf("无效的FQC地址 '%s': %v", e.URL, e.Err)
+}
+
+func (e *InvalidURLError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError 请求的响应或确认发送的投递回执状态码不是 2xx
+type StatusError struct {
+	From string           // 响应方的客户端名称，确认发送时为发送目标
+	Code codec.StatusCode // 状态码
+}
+
+func (e *StatusError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("状态码 %d", e.Code)
+	}
+	return fmt.Sprintf("%s: 状态码 %d", e.From, e.Code)
+}
+
+// Err 状态码为 2xx 时返回 nil，否则返回 *StatusError
+//
+// 使用方式:
+//
+//	resp, err := client.Request(ctx, "target-client", "/user/info", nil)
+//	if err == nil {
+//	    err = resp.Err()
+//	}
+//	var se *fernqclient.StatusError
+//	if errors.As(err, &se) && se.Code == codec.StatusNotFound {
+//	    // 目标未注册该地址
+//	}
+func (r *Response) Err() error {
+	if r.Status >= 200 && r.Status < 300 {
+		return nil
+	}
+	return &StatusError{From: r.From, Code: r.Status}
+}
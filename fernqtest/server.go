@@ -0,0 +1,72 @@
+// Package fernqtest 提供进程内的 FernQ 参考服务器，用于编写不依赖真实服务器的集成测试
+//
+// 使用方式:
+//
+//	srv := fernqtest.NewServer(t)
+//
+//	client := fernqclient.NewClient("alice")
+//	if err := client.Connect(srv.RoomURL("uuid", "room", "password")); err != nil {
+//	    t.Fatal(err)
+//	}
+package fernqtest
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/xfs0205/fernqclient/server"
+)
+
+// Server 监听本地回环地址的 FernQ 服务器
+//
+// 协议实现与路由规则见 server 包，房间密码由 AddRoom 预先设置。
+type Server struct {
+	Addr string // 监听地址，如 127.0.0.1:54321
+
+	*server.Server
+	done chan struct{}
+}
+
+// NewServer 创建并启动服务器，监听 127.0.0.1 的随机端口
+//
+// 开启 OpenRooms，未通过 AddRoom 设置的房间在第一个客户端加入时创建，密码取该客户端提供的 room_pass。
+// 监听失败时调用 t.Fatal，测试结束时自动关闭。
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	return NewServerConfig(t, server.Config{OpenRooms: true})
+}
+
+// NewServerConfig 使用指定配置创建并启动服务器，监听 127.0.0.1 的随机端口
+//
+// 配置原样交给 server.New，需要自动创建房间时设置 OpenRooms。
+// 监听失败时调用 t.Fatal，测试结束时自动关闭。
+func NewServerConfig(t testing.TB, cfg server.Config) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fernqtest: 监听失败: %v", err)
+	}
+	s := &Server{
+		Addr:   ln.Addr().String(),
+		Server: server.New(cfg),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		s.Serve(ln)
+	}()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// RoomURL 返回连接指定房间的地址
+func (s *Server) RoomURL(uuid, roomName, password string) string {
+	return fmt.Sprintf("fernq://connect/%s/%s#%s?room_pass=%s", s.Addr, uuid, roomName, password)
+}
+
+// Close 停止监听并断开所有连接，等待所有协程退出，可以重复调用
+func (s *Server) Close() {
+	s.Server.Close()
+	<-s.done
+}
@@ -0,0 +1,64 @@
+package fernqtest_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/fernqtest"
+	"github.com/xfs0205/fernqclient/server"
+)
+
+// 连接服务器，返回连接错误，成功时测试结束后停止客户端
+func connect(t *testing.T, url, name string) error {
+	t.Helper()
+	c := fernqclient.NewClient(name)
+	if err := c.Connect(url); err != nil {
+		return err
+	}
+	t.Cleanup(func() { c.Stop() })
+	return nil
+}
+
+// NewServer 开启 OpenRooms，未设置的房间由第一个客户端创建
+func TestNewServer(t *testing.T) {
+	s := fernqtest.NewServer(t)
+	if err := connect(t, s.RoomURL("uuid", "room", "pass"), "alice"); err != nil {
+		t.Fatalf("加入新房间失败: %v", err)
+	}
+	if got := s.Clients("uuid", "room"); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("房间成员为 %v", got)
+	}
+
+	var authErr *fernqclient.AuthError
+	if err := connect(t, s.RoomURL("uuid", "room", "wrong"), "bob"); !errors.As(err, &authErr) {
+		t.Fatalf("密码错误时返回 %v，期望 *AuthError", err)
+	}
+}
+
+// NewServerConfig 原样使用配置，未开启 OpenRooms 时只能加入 AddRoom 设置的房间
+func TestNewServerConfig(t *testing.T) {
+	s := fernqtest.NewServerConfig(t, server.Config{})
+	s.AddRoom("uuid", "room", "pass")
+
+	var authErr *fernqclient.AuthError
+	if err := connect(t, s.RoomURL("uuid", "other", "pass"), "alice"); !errors.As(err, &authErr) {
+		t.Fatalf("加入未创建的房间返回 %v，期望 *AuthError", err)
+	}
+	if err := connect(t, s.RoomURL("uuid", "room", "wrong"), "alice"); !errors.As(err, &authErr) {
+		t.Fatalf("密码错误时返回 %v，期望 *AuthError", err)
+	}
+	if err := connect(t, s.RoomURL("uuid", "room", "pass"), "alice"); err != nil {
+		t.Fatalf("加入已创建的房间失败: %v", err)
+	}
+}
+
+// 测试中可以提前关闭，测试结束时再次关闭不会阻塞
+func TestClose(t *testing.T) {
+	s := fernqtest.NewServer(t)
+	s.Close()
+	if err := connect(t, s.RoomURL("uuid", "room", "pass"), "alice"); err == nil {
+		t.Fatal("服务器关闭后仍可以连接")
+	}
+}
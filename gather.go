@@ -0,0 +1,134 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// RequestAll 未设置截止时间时的默认等待时长
+const DefaultGatherTimeout = 5 * time.Second
+
+// GatherOption RequestAll 的提前结束条件
+type GatherOption func(*gatherOptions)
+
+type gatherOptions struct {
+	first  int // 收到任意 n 个响应后结束
+	quorum int // 收到 n 个成功(2xx)响应后结束
+}
+
+// GatherFirst 收到任意 n 个响应后立即返回
+func GatherFirst(n int) GatherOption {
+	return func(o *gatherOptions) {
+		o.first = n
+	}
+}
+
+// GatherQuorum 收到 n 个成功(2xx)响应后立即返回
+func GatherQuorum(n int) GatherOption {
+	return func(o *gatherOptions) {
+		o.quorum = n
+	}
+}
+
+// RequestAll 扫描请求模式(属于组播模式)，向所有正则表达式匹配的用户发送请求并收集响应
+// 参数:
+//   - ctx: 上下文，其截止时间即收集窗口；未设置截止时间时使用 DefaultGatherTimeout
+//   - pattern: 用于匹配目标用户的正则表达式
+//   - url: 请求地址
+//   - body: 请求体
+//   - opts: 提前结束条件，如 GatherFirst(n)、GatherQuorum(n)；不传时一直收集到截止时间
+//
+// 返回值:
+//   - []*Response: 已收到的响应，按到达顺序排列，Response.From 为响应方名称
+//   - error: 请求过程中的错误
+//     说明：
+//   - 未设置提前结束条件时，到达截止时间属于正常结束，返回 nil；
+//     但截止时间前一个响应都没有收到时返回 ErrNoResponse
+//   - 设置了提前结束条件但截止时间前未满足时，返回已收到的响应和超时错误
+//   - 上下文被取消或连接断开时，返回已收到的响应和对应错误
+//
+// 注意事项:
+//   - 需要服务器支持 TypeRequestMessageAll（fernqd、fernqtest），不支持的服务器不会转发请求，
+//     调用会等到截止时间并返回 ErrNoResponse，与没有匹配的客户端无法区分
+//
+// 使用方式:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+//	defer cancel()
+//	// 询问所有 worker 的健康状态，收集到截止时间
+//	resps, err := client.RequestAll(ctx, "worker-[0-9]+", "/health", nil)
+//	// 收到 3 个成功响应即返回
+//	resps, err = client.RequestAll(ctx, "worker-[0-9]+", "/config", nil, fernqclient.GatherQuorum(3))
+func (c *Client) RequestAll(ctx context.Context, pattern, url string, body []byte, opts ...GatherOption) ([]*Response, error) {
+	// 验证正则表达式有效性
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("无效的正则表达式 '%s': %w", pattern, err)
+	}
+
+	var o gatherOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// 未设置截止时间时使用默认收集窗口
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultGatherTimeout)
+		defer cancel()
+	}
+
+	id, data, err := codec.CreateRequestMessageAll(pattern, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建扫描请求消息失败: %w", err)
+	}
+
+	call := &pendingCall{
+		ch:    make(chan *Response, 16),
+		done:  make(chan struct{}),
+		multi: true,
+	}
+	c.addPending(id, call)
+	defer func() {
+		c.removePending(id)
+		close(call.done)
+	}()
+
+	if err := c.safeWrite(data); err != nil {
+		return nil, err
+	}
+
+	var resps []*Response
+	succeeded := 0
+	for {
+		select {
+		case resp, ok := <-call.ch:
+			if !ok {
+				return resps, ErrConnectionLost
+			}
+			resps = append(resps, resp)
+			if resp.Status >= 200 && resp.Status < 300 {
+				succeeded++
+			}
+			if o.first > 0 && len(resps) >= o.first {
+				return resps, nil
+			}
+			if o.quorum > 0 && succeeded >= o.quorum {
+				return resps, nil
+			}
+		case <-ctx.Done():
+			err := ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) && o.first <= 0 && o.quorum <= 0 {
+				if len(resps) == 0 {
+					return nil, ErrNoResponse
+				}
+				return resps, nil
+			}
+			return resps, fmt.Errorf("收到 %d 个响应后结束: %w", len(resps), err)
+		}
+	}
+}
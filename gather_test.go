@@ -0,0 +1,94 @@
+package fernqclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/fernqtest"
+)
+
+// 启动 n 个处理 /health 的 worker，第 i 个延迟 i*delay 后响应
+func startWorkers(t *testing.T, s *fernqtest.Server, n int, delay time.Duration) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		wait := time.Duration(i) * delay
+		w := fernqclient.NewClient(fmt.Sprintf("worker-%d", i))
+		w.Handle("/health", func(ctx context.Context, req *fernqclient.Request) *fernqclient.Response {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+			return &fernqclient.Response{Body: []byte("ok")}
+		})
+		if err := w.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { w.Stop() })
+	}
+}
+
+// 未设置提前结束条件时等到截止时间，收集所有响应
+func TestRequestAllDeadline(t *testing.T) {
+	s := newServer(t)
+	startWorkers(t, s, 3, 0)
+	client := connect(t, s, "client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	resps, err := client.RequestAll(ctx, "worker-[0-9]+", "/health", nil)
+	if err != nil {
+		t.Fatalf("RequestAll: %v", err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("收到 %d 个响应，期望 3 个", len(resps))
+	}
+}
+
+// GatherFirst、GatherQuorum 满足条件后立即返回，不等待慢响应
+func TestRequestAllEarlyStop(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  fernqclient.GatherOption
+	}{
+		{"first", fernqclient.GatherFirst(2)},
+		{"quorum", fernqclient.GatherQuorum(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t)
+			startWorkers(t, s, 3, time.Second)
+			client := connect(t, s, "client")
+
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			start := time.Now()
+			resps, err := client.RequestAll(ctx, "worker-[0-9]+", "/health", nil, tt.opt)
+			if err != nil {
+				t.Fatalf("RequestAll: %v", err)
+			}
+			if len(resps) != 2 {
+				t.Fatalf("收到 %d 个响应，期望 2 个", len(resps))
+			}
+			if d := time.Since(start); d > 1900*time.Millisecond {
+				t.Fatalf("耗时 %v，应在第二个响应后立即返回", d)
+			}
+		})
+	}
+}
+
+// 截止时间前没有任何响应时返回 ErrNoResponse
+func TestRequestAllNoResponse(t *testing.T) {
+	s := newServer(t)
+	client := connect(t, s, "client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err := client.RequestAll(ctx, "worker-[0-9]+", "/health", nil)
+	if !errors.Is(err, fernqclient.ErrNoResponse) {
+		t.Fatalf("错误为 %v，期望 ErrNoResponse", err)
+	}
+}
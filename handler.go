@@ -0,0 +1,150 @@
+package fernqclient
+
+import (
+	"context"
+	"log"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 默认的最大并发请求处理数
+const DefaultMaxConcurrentRequests = 256
+
+// WithMaxConcurrentRequests 设置同时执行的请求处理函数数量上限
+//
+// 参数:
+//   - n: 并发上限，<= 0 时使用 DefaultMaxConcurrentRequests
+//
+// 达到上限后新到达的请求立即回复 StatusServiceUnavailable，不会阻塞读取协程。
+func WithMaxConcurrentRequests(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentRequests = n
+	}
+}
+
+// 获取最大并发请求处理数
+func (o *options) maxConcurrentRequestsOrDefault() int {
+	if o.maxConcurrentRequests > 0 {
+		return o.maxConcurrentRequests
+	}
+	return DefaultMaxConcurrentRequests
+}
+
+// 收到的请求
+type Request struct {
+	From string // 请求方的客户端名称
+	Url  string // 请求地址
+	Body []byte // 请求体
+}
+
+// HandlerFunc 请求处理函数，返回的响应会自动发回请求方
+//   - 返回 nil 时回复 StatusNoContent
+//   - Response.Status 为 0 时按 StatusOK 处理
+//   - Response.From 会被忽略
+type HandlerFunc func(ctx context.Context, req *Request) *Response
+
+// Handle 注册请求处理函数，当其他客户端向本客户端发送请求时按 url 分发
+// 参数:
+//   - pattern: 请求地址，与 RequestBody.Url 完全匹配
+//   - handler: 处理函数，传入 nil 表示注销该地址
+//
+// 说明:
+//   - 未注册的地址自动回复 StatusNotFound
+//   - 处理函数发生 panic 时自动回复 StatusInternalServerError
+//   - 每个请求在独立的 goroutine 中处理，ctx 在调用 Stop() 或收到请求的连接断开后取消
+//   - 同时处理的请求数受 WithMaxConcurrentRequests 限制，超出时回复 StatusServiceUnavailable
+//
+// 使用方式:
+//
+//	client.Handle("/user/info", func(ctx context.Context, req *fernqclient.Request) *fernqclient.Response {
+//	    return &fernqclient.Response{Status: codec.StatusOK, Body: []byte("hello " + req.From)}
+//	})
+func (c *Client) Handle(pattern string, handler HandlerFunc) {
+	c.handlersMu.Lock()
+	defer c.handlersMu.Unlock()
+	if handler == nil {
+		delete(c.handlers, pattern)
+		return
+	}
+	if c.handlers == nil {
+		c.handlers = make(map[string]HandlerFunc)
+	}
+	c.handlers[pattern] = handler
+}
+
+// 解析收到的请求并交给处理函数
+func (c *Client) dispatchRequest(ctx context.Context, body []byte) {
+	message, err := codec.DecodeReceiveMessagePB(body)
+	if err != nil {
+		log.Println("解析请求失败")
+		return
+	}
+	id, req, err := codec.ParseRequestReceiveMessage(message.Message)
+	if err != nil {
+		log.Println("解析请求体失败")
+		return
+	}
+
+	c.handlersMu.RLock()
+	handler := c.handlers[req.Url]
+	c.handlersMu.RUnlock()
+
+	r := &Request{
+		From: message.From,
+		Url:  req.Url,
+		Body: req.Body,
+	}
+	slots := c.requestSlots
+	select {
+	case slots <- struct{}{}:
+	default:
+		// 处理中的请求过多，直接拒绝，避免请求洪泛创建大量协程
+		log.Printf("处理中的请求已达上限，拒绝来自 %s 的请求 %s", r.From, r.Url)
+		c.replyRequest(id, r.From, codec.StatusServiceUnavailable, nil)
+		return
+	}
+	go func() {
+		defer func() { <-slots }()
+		c.serveRequest(ctx, id, handler, r)
+	}()
+}
+
+// 执行处理函数并回复响应
+func (c *Client) serveRequest(ctx context.Context, id []byte, handler HandlerFunc, req *Request) {
+	status, body := c.callHandler(ctx, handler, req)
+	c.replyRequest(id, req.From, status, body)
+}
+
+// 向请求方回复响应
+func (c *Client) replyRequest(id []byte, to string, status codec.StatusCode, body []byte) {
+	data, err := codec.CreateResponseMessage(to, id, body, status)
+	if err != nil {
+		log.Println("创建响应失败")
+		return
+	}
+	if err := c.safeWrite(data); err != nil {
+		log.Println("发送响应失败")
+	}
+}
+
+// 调用处理函数，处理未注册地址与 panic
+func (c *Client) callHandler(ctx context.Context, handler HandlerFunc, req *Request) (status codec.StatusCode, body []byte) {
+	if handler == nil {
+		return codec.StatusNotFound, nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("处理请求 %s 时发生panic: %v", req.Url, r)
+			status, body = codec.StatusInternalServerError, nil
+		}
+	}()
+
+	resp := handler(ctx, req)
+	if resp == nil {
+		return codec.StatusNoContent, nil
+	}
+	if resp.Status == 0 {
+		return codec.StatusOK, resp.Body
+	}
+	return resp.Status, resp.Body
+}
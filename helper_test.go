@@ -0,0 +1,57 @@
+package fernqclient_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/fernqtest"
+)
+
+// 测试中等待消息的超时时间
+const testTimeout = 5 * time.Second
+
+// 启动测试服务器，测试结束时关闭
+func newServer(t testing.TB) *fernqtest.Server {
+	t.Helper()
+	return fernqtest.NewServer(t)
+}
+
+// 创建客户端并连接测试服务器的 test 房间，测试结束时停止
+func connect(t testing.TB, s *fernqtest.Server, name string, opts ...fernqclient.Option) *fernqclient.Client {
+	t.Helper()
+	c := fernqclient.NewClient(name, opts...)
+	if err := c.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatalf("%s 连接失败: %v", name, err)
+	}
+	t.Cleanup(func() { c.Stop() })
+	return c
+}
+
+// 从 Read() 通道读取一条消息
+func receive(t testing.TB, c *fernqclient.Client) fernqclient.FernqMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-c.Read():
+		if !ok {
+			t.Fatalf("%s 的接收通道已关闭", c.ClientName)
+		}
+		return msg
+	case <-time.After(testTimeout):
+		t.Fatalf("%s 等待消息超时", c.ClientName)
+	}
+	return fernqclient.FernqMessage{}
+}
+
+// 等待服务器将客户端移出 test 房间，之后才能以相同名称再次连接
+func waitLeft(t testing.TB, s *fernqtest.Server, name string) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for slices.Contains(s.Clients("uuid", "test"), name) {
+		if time.Now().After(deadline) {
+			t.Fatalf("服务器未移除已停止的客户端 %s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
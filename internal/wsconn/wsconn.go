@@ -0,0 +1,280 @@
+// Package wsconn 实现 FernQ 使用的 WebSocket 子集（RFC 6455），供客户端传输与服务器共用
+//
+// 只支持二进制消息承载字节流，不支持扩展与子协议。
+package wsconn
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket 握手使用的 GUID (RFC 6455)
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket 帧操作码
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// ErrBadHandshake 升级请求无效
+var ErrBadHandshake = errors.New("wsconn: 无效的 WebSocket 升级请求")
+
+// AcceptKey 计算 Sec-WebSocket-Accept
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// NewKey 生成随机的 Sec-WebSocket-Key
+func NewKey() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
+
+// CheckUpgrade 检查请求是否为有效的 WebSocket 升级请求
+func CheckUpgrade(r *http.Request) error {
+	if r.Method != http.MethodGet ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		r.Header.Get("Sec-WebSocket-Key") == "" {
+		return ErrBadHandshake
+	}
+	return nil
+}
+
+// Upgrade 完成服务端握手，返回 WebSocket 连接
+//
+// 请求无效时回复 400 并返回 ErrBadHandshake；成功时连接已从 HTTP 服务器接管，由调用方负责关闭。
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if err := CheckUpgrade(r); err != nil {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, err
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("wsconn: ResponseWriter 不支持 Hijack")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// HTTP 服务器可能设置了超时，交给调用方重新设置
+	conn.SetDeadline(time.Time{})
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", AcceptKey(r.Header.Get("Sec-WebSocket-Key")))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return New(conn, brw.Reader, false), nil
+}
+
+// 检查逗号分隔的请求头是否包含指定值
+func headerHasToken(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Conn WebSocket 连接，将二进制消息的负载作为字节流读写，实现 net.Conn
+type Conn struct {
+	net.Conn
+	br     *bufio.Reader
+	client bool // 客户端发送的帧需要掩码
+
+	readMu  sync.Mutex
+	remain  int64   // 当前数据帧剩余未读的负载长度
+	masked  bool    // 当前数据帧是否带掩码
+	mask    [4]byte // 当前数据帧的掩码
+	maskPos int     // 掩码偏移
+
+	writeMu sync.Mutex
+}
+
+// New 在已完成握手的连接上创建 WebSocket 连接
+// 参数:
+//   - conn: 底层连接
+//   - br: 读取握手响应时使用的缓冲，可能已包含后续数据帧
+//   - client: 是否为客户端，客户端发送的帧带掩码
+func New(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	return &Conn{Conn: conn, br: br, client: client}
+}
+
+// Read 读取数据帧负载，自动处理控制帧
+func (w *Conn) Read(p []byte) (int, error) {
+	w.readMu.Lock()
+	defer w.readMu.Unlock()
+	for w.remain == 0 {
+		if err := w.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > w.remain {
+		p = p[:w.remain]
+	}
+	n, err := w.br.Read(p)
+	if w.masked {
+		for i := 0; i < n; i++ {
+			p[i] ^= w.mask[w.maskPos&3]
+			w.maskPos++
+		}
+	}
+	w.remain -= int64(n)
+	return n, err
+}
+
+// 读取下一个帧头，控制帧在此处理完毕，数据帧留给 Read 读取负载
+//
+// 帧头与控制帧先通过 Peek 读取完整后再消费，读取超时不会破坏数据流
+func (w *Conn) nextFrame() error {
+	head, err := w.br.Peek(2)
+	if err != nil {
+		return err
+	}
+	opcode := head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	length := int64(head[1] & 0x7F)
+	headLen := 2
+	switch length {
+	case 126:
+		headLen += 2
+	case 127:
+		headLen += 8
+	}
+	if masked {
+		headLen += 4
+	}
+	if head, err = w.br.Peek(headLen); err != nil {
+		return err
+	}
+	switch length {
+	case 126:
+		length = int64(binary.BigEndian.Uint16(head[2:4]))
+	case 127:
+		length = int64(binary.BigEndian.Uint64(head[2:10]))
+		if length < 0 {
+			return fmt.Errorf("WebSocket: 无效的帧长度")
+		}
+	}
+	var mask [4]byte
+	if masked {
+		copy(mask[:], head[headLen-4:])
+	}
+
+	switch opcode {
+	case opBinary, opText, opContinuation:
+		w.br.Discard(headLen)
+		w.remain = length
+		w.masked = masked
+		w.mask = mask
+		w.maskPos = 0
+		return nil
+	case opPing, opPong, opClose:
+		if length > 125 {
+			return fmt.Errorf("WebSocket: 控制帧过长")
+		}
+		frame, err := w.br.Peek(headLen + int(length))
+		if err != nil {
+			return err
+		}
+		payload := append([]byte(nil), frame[headLen:]...)
+		w.br.Discard(len(frame))
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i&3]
+			}
+		}
+		switch opcode {
+		case opPing:
+			return w.writeFrame(opPong, payload)
+		case opClose:
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			w.writeFrame(opClose, payload)
+			return io.EOF
+		}
+		return nil
+	default:
+		return fmt.Errorf("WebSocket: 未知的操作码 %d", opcode)
+	}
+}
+
+// Write 以一个二进制消息发送数据
+func (w *Conn) Write(p []byte) (int, error) {
+	if err := w.writeFrame(opBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close 发送关闭帧后关闭底层连接
+func (w *Conn) Close() error {
+	w.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+	w.writeFrame(opClose, nil)
+	return w.Conn.Close()
+}
+
+// 发送一个完整的帧
+func (w *Conn) writeFrame(opcode byte, payload []byte) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if w.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if w.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i&3]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := w.Conn.Write(frame)
+	return err
+}
@@ -0,0 +1,158 @@
+package wsconn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// 本地回环上的一对 WebSocket 连接，返回客户端与服务端
+func pair(t *testing.T) (*Conn, *Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	a, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return New(a, bufio.NewReader(a), true), New(b, bufio.NewReader(b), false)
+}
+
+// 各种长度编码的帧在两个方向上都能完整读出
+func TestRoundTrip(t *testing.T) {
+	client, server := pair(t)
+	for _, size := range []int{1, 125, 126, 0xFFFF, 0x10000, 200 << 10} {
+		payload := bytes.Repeat([]byte{byte(size)}, size)
+		for _, dir := range [][2]*Conn{{client, server}, {server, client}} {
+			w, r := dir[0], dir[1]
+			errc := make(chan error, 1)
+			go func() {
+				_, err := w.Write(payload)
+				errc <- err
+			}()
+			got := make([]byte, size)
+			if _, err := io.ReadFull(r, got); err != nil {
+				t.Fatalf("%d 字节: %v", size, err)
+			}
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Fatalf("%d 字节的负载不一致", size)
+			}
+		}
+	}
+}
+
+// 收到 Ping 时回复带相同负载的 Pong，客户端发送的帧带掩码
+func TestPing(t *testing.T) {
+	client, server := pair(t)
+	if err := server.writeFrame(opPing, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.writeFrame(opBinary, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := client.Read(buf)
+	if err != nil || string(buf[:n]) != "data" {
+		t.Fatalf("读取到 %q %v，期望 data", buf[:n], err)
+	}
+
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	head, err := server.br.Peek(2 + 4 + 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head[0]&0x0F != opPong || head[1] != 0x80|2 {
+		t.Fatalf("回复的帧头为 %x，期望带掩码的 2 字节 Pong", head[:2])
+	}
+	payload := []byte{head[6] ^ head[2], head[7] ^ head[3]}
+	if string(payload) != "hi" {
+		t.Fatalf("Pong 负载为 %q，期望 hi", payload)
+	}
+}
+
+// 收到关闭帧时回复关闭帧并返回 io.EOF
+func TestClose(t *testing.T) {
+	client, server := pair(t)
+	if err := server.writeFrame(opClose, []byte{0x03, 0xE8, 'b', 'y', 'e'}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("返回 %v，期望 io.EOF", err)
+	}
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := server.Read(make([]byte, 16)); err != io.EOF {
+		t.Fatalf("对端返回 %v，期望 io.EOF", err)
+	}
+}
+
+// 帧头只到达一部分时读取超时，不破坏数据流，之后可以继续读取
+func TestReadTimeout(t *testing.T) {
+	client, server := pair(t)
+	frame := []byte{0x80 | opBinary, 126, 0, 200}
+	frame = append(frame, bytes.Repeat([]byte("x"), 200)...)
+	if _, err := server.Conn.Write(frame[:3]); err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	_, err := client.Read(make([]byte, 256))
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("返回 %v，期望超时错误", err)
+	}
+
+	if _, err := server.Conn.Write(frame[3:]); err != nil {
+		t.Fatal(err)
+	}
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	got := make([]byte, 200)
+	if _, err := io.ReadFull(client, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, frame[4:]) {
+		t.Fatal("超时后读取的负载不一致")
+	}
+}
+
+// 控制帧超过 125 字节或操作码未知时返回错误
+func TestInvalidFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+	}{
+		{"long control", append([]byte{0x80 | opPing, 126, 0, 126}, make([]byte, 126)...)},
+		{"unknown opcode", []byte{0x80 | 0x3, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := pair(t)
+			if _, err := server.Conn.Write(tt.frame); err != nil {
+				t.Fatal(err)
+			}
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, err := client.Read(make([]byte, 16))
+			var ne net.Error
+			if err == nil || err == io.EOF || errors.As(err, &ne) {
+				t.Fatalf("返回 %v，期望帧格式错误", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package fernqclient
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// WithKeepalive 开启客户端主动心跳
+//
+// 参数:
+//   - interval: 发送 Ping 的间隔
+//   - maxMissed: 连续多少个间隔内未收到 Pong 时判定连接失效，<= 0 时按 3 处理
+//
+// 连接失效后会被主动关闭，开启断线重连时触发重连，否则 Read() 通道随之关闭。
+// 用于发现 NAT 后半开的 TCP 连接等服务器无响应的情况。
+func WithKeepalive(interval time.Duration, maxMissed int) Option {
+	return func(o *options) {
+		if maxMissed <= 0 {
+			maxMissed = 3
+		}
+		o.keepaliveInterval = interval
+		o.keepaliveMaxMissed = maxMissed
+	}
+}
+
+// 心跳协程，定时发送 Ping，连续 maxMissed 个间隔未收到 Pong 时关闭连接
+func (c *Client) keepalive(closer *connCloser, pong <-chan struct{}, done <-chan struct{}) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.opts.keepaliveInterval)
+		defer ticker.Stop()
+
+		missed := 0       // 连续未收到响应的 Ping 数
+		awaiting := false // 是否有尚未收到响应的 Ping
+		for {
+			select {
+			case <-done:
+				return
+			case <-pong:
+				missed = 0
+				awaiting = false
+				continue
+			case <-ticker.C:
+			}
+
+			// 读取协程阻塞在接收通道上时无法读到 Pong，不计为丢失
+			if awaiting && c.readBlocked.Load() {
+				continue
+			}
+
+			// 上一次 Ping 在本间隔内未收到响应
+			if awaiting {
+				missed++
+				if missed >= c.opts.keepaliveMaxMissed {
+					log.Printf("连续 %d 次未收到心跳响应，断开连接", missed)
+					closer.close(fmt.Errorf("%w: 连续 %d 次未收到响应", ErrKeepaliveTimeout, missed))
+					return
+				}
+			}
+
+			if err := c.sendControl(pingFrame); err != nil {
+				log.Println("发送ping失败")
+			}
+			awaiting = true
+		}
+	}()
+}
@@ -0,0 +1,142 @@
+package fernqclient_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 可以模拟半开连接的 TCP 传输方式，停止后读取不到任何数据，写入照常成功
+type stallTransport struct {
+	stalled atomic.Bool
+}
+
+func (s *stallTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &stallConn{Conn: conn, stalled: &s.stalled, closed: make(chan struct{})}, nil
+}
+
+type stallConn struct {
+	net.Conn
+	stalled *atomic.Bool
+
+	mu       sync.Mutex
+	deadline time.Time
+	once     sync.Once
+	closed   chan struct{}
+}
+
+func (c *stallConn) Read(p []byte) (int, error) {
+	if !c.stalled.Load() {
+		return c.Conn.Read(p)
+	}
+	// 停止后一直等到读取超时或连接关闭
+	c.mu.Lock()
+	wait := time.Until(c.deadline)
+	c.mu.Unlock()
+	select {
+	case <-time.After(wait):
+		return 0, os.ErrDeadlineExceeded
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+func (c *stallConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *stallConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
+
+// 记录状态变化中的关闭原因
+func closeReasons(c *fernqclient.Client) <-chan error {
+	reasons := make(chan error, 16)
+	c.OnStateChange(func(ev fernqclient.StateEvent) {
+		if ev.Err != nil {
+			select {
+			case reasons <- ev.Err:
+			default:
+			}
+		}
+	})
+	return reasons
+}
+
+// 服务器无响应时连续丢失心跳响应后断开连接，关闭原因为 ErrKeepaliveTimeout
+func TestKeepaliveTimeout(t *testing.T) {
+	s := newServer(t)
+	stall := &stallTransport{}
+	c := connect(t, s, "edge", fernqclient.WithKeepalive(20*time.Millisecond, 2), fernqclient.WithTransport(stall))
+	reasons := closeReasons(c)
+
+	// 连接正常时心跳响应按时到达，不会断开
+	time.Sleep(200 * time.Millisecond)
+	if state := c.State(); state != fernqclient.StateConnected {
+		t.Fatalf("心跳正常时状态为 %s", state)
+	}
+
+	stall.stalled.Store(true)
+	select {
+	case err := <-reasons:
+		if !errors.Is(err, fernqclient.ErrKeepaliveTimeout) {
+			t.Fatalf("关闭原因为 %v，期望 ErrKeepaliveTimeout", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("服务器无响应时未断开连接")
+	}
+}
+
+// 读取协程阻塞在接收通道上时收不到心跳响应，不计为丢失
+func TestKeepaliveReadBlocked(t *testing.T) {
+	s := newServer(t)
+	c := connect(t, s, "edge",
+		fernqclient.WithKeepalive(20*time.Millisecond, 2),
+		fernqclient.WithReadBuffer(1, fernqclient.OverflowBlock))
+	reasons := closeReasons(c)
+	sender := connect(t, s, "sender")
+
+	// 第一条消息填满接收通道，第二条使读取协程阻塞
+	for _, m := range []string{"a", "b"} {
+		if err := sender.Send("edge", []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	// 心跳协程关闭连接时读取协程仍阻塞，消费后才能观察到断开，因此再收发一条消息确认连接正常
+	for _, want := range []string{"a", "b"} {
+		if msg := receive(t, c); string(msg.Message) != want {
+			t.Fatalf("收到 %q，期望 %q", msg.Message, want)
+		}
+	}
+	if err := sender.Send("edge", []byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg, ok := <-c.Read():
+		if !ok || string(msg.Message) != "c" {
+			t.Fatal("读取协程阻塞期间连接被断开")
+		}
+	case err := <-reasons:
+		t.Fatalf("读取协程阻塞期间断开连接: %v", err)
+	case <-time.After(testTimeout):
+		t.Fatal("等待消息超时")
+	}
+}
@@ -0,0 +1,87 @@
+package fernqclient
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/xfs0205/fernqclient/outbox"
+)
+
+// 默认的房间验证超时时间
+const DefaultHandshakeTimeout = 3 * time.Minute
+
+// Option 客户端可选配置，在 NewClient 或 ConnectContext 中传入
+type Option func(*options)
+
+// 客户端选项
+type options struct {
+	reconnect *ReconnectPolicy // 断线重连策略，nil 表示不重连
+	reliable  *ReliablePolicy  // 可靠投递策略，nil 表示不处理收到的可靠消息
+	outbox    *outbox.Outbox   // 持久化发件箱，nil 表示不使用
+
+	transport        Transport     // 传输方式，nil 表示 TCP 直连
+	dialTimeout      time.Duration // 拨号超时，0 表示仅受上下文控制
+	handshakeTimeout time.Duration // 等待房间验证结果的超时，0 表示使用 DefaultHandshakeTimeout
+	readIdleTimeout  time.Duration // 读取空闲超时，0 表示不检测
+	maxFrameSize     int           // 最大帧长度，0 表示使用 codec.DefaultMaxFrameSize
+	writeTimeout     time.Duration // 单次写入超时，0 表示不限制
+
+	sendQueueSize   int             // 发送队列长度，0 表示使用 DefaultSendQueueSize
+	sendQueuePolicy SendQueuePolicy // 发送队列已满时的处理策略
+
+	readBufferSize int            // 接收通道容量，0 表示使用 DefaultReadBufferSize
+	overflowPolicy OverflowPolicy // 接收通道已满时的处理策略
+
+	dispatchWorkers int  // 消息回调协程数，0 表示使用 DefaultDispatchWorkers
+	dispatchOrdered bool // 同一发送方的消息是否串行处理
+
+	maxConcurrentRequests int // 同时执行的请求处理函数数量上限，0 表示使用 DefaultMaxConcurrentRequests
+
+	endpointOrder EndpointOrder // 多节点时的连接顺序
+	resolver      Resolver      // SRV 记录解析器，nil 表示使用 net.DefaultResolver
+
+	keepaliveInterval  time.Duration // 主动心跳间隔，0 表示不主动发送心跳
+	keepaliveMaxMissed int           // 允许连续丢失的心跳响应数
+
+	tlsConfig *tls.Config // TLS 配置，非 nil 时对所有地址启用 TLS
+	tlsPins   []string    // 固定的服务器证书公钥指纹
+}
+
+// WithDialTimeout 设置建立底层连接的超时时间
+func WithDialTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.dialTimeout = d
+	}
+}
+
+// WithHandshakeTimeout 设置发送验证消息后等待验证结果的超时时间，默认 DefaultHandshakeTimeout
+func WithHandshakeTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.handshakeTimeout = d
+	}
+}
+
+// WithReadIdleTimeout 设置读取空闲超时，超过该时间未收到任何数据（包括心跳）时断开连接，
+// 开启断线重连时会触发重连。默认不检测
+func WithReadIdleTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.readIdleTimeout = d
+	}
+}
+
+// WithMaxFrameSize 设置接收帧的最大长度（包括帧头），默认 codec.DefaultMaxFrameSize
+//
+// 收到帧头声明的长度超过上限时立即断开连接，不会为其分配内存。
+func WithMaxFrameSize(n int) Option {
+	return func(o *options) {
+		o.maxFrameSize = n
+	}
+}
+
+// 获取验证超时时间
+func (o *options) handshakeTimeoutOrDefault() time.Duration {
+	if o.handshakeTimeout > 0 {
+		return o.handshakeTimeout
+	}
+	return DefaultHandshakeTimeout
+}
@@ -0,0 +1,136 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/xfs0205/fernqclient/outbox"
+)
+
+// WithOutbox 开启持久化发件箱
+//
+// 开启后，Send、Broadcast、ScanSend、UserScanSingle 先将消息写入发件箱再返回，
+// 由发送协程按顺序加入发送队列，写入连接后从发件箱提交。
+// Connect 之前和断线重连期间发送不再返回错误，消息保留在磁盘上，连接建立后按顺序发送。
+//
+// 调用 Stop() 之后发送返回 ErrClosed，直到再次连接成功；Stop 时尚未发出的消息保留在磁盘上，
+// 再次 Connect 或进程重启后重新打开同一目录时继续发送。
+// 未开启断线重连时连接断开、或超过最大重连次数后，发送仍写入发件箱，但只有再次调用 Connect 后才会发出。
+//
+// 参数:
+//   - ob: 由 outbox.Open 打开的发件箱，客户端不负责关闭
+//
+// 注意事项:
+//   - 应在 NewClient 中传入，Connect 之前的发送才会进入发件箱
+//   - 写出期间断线或进程退出时，未提交的消息会被重新发送，对方可能重复收到
+//   - 写入连接不代表对方已收到，需要确认时结合 SendReliable 或 SendConfirmed
+//   - 请求、确认发送与可靠投递不经过发件箱，不保证与发件箱中的消息之间的顺序
+//   - 发件箱中的消息不受 WithSendQueue 的队列已满策略影响
+//
+// 使用方式:
+//
+//	ob, err := outbox.Open("/var/lib/app/outbox", outbox.Config{MaxBytes: 64 << 20})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer ob.Close()
+//	client := fernqclient.NewClient("edge-1", fernqclient.WithOutbox(ob), fernqclient.WithReconnect(fernqclient.DefaultReconnectPolicy))
+func WithOutbox(ob *outbox.Outbox) Option {
+	return func(o *options) {
+		o.outbox = ob
+	}
+}
+
+// 发送消息帧，开启发件箱时写入发件箱
+func (c *Client) post(encode func(dst []byte) []byte) error {
+	ob := c.opts.outbox
+	if ob == nil {
+		return c.writeFrame(encode)
+	}
+	c.statusMu.Lock()
+	stopped := c.stopped
+	c.statusMu.Unlock()
+	if stopped {
+		return ErrClosed
+	}
+
+	buf := getFrameBuffer()
+	*buf = encode(*buf)
+	err := ob.Append(*buf)
+	putFrameBuffer(buf)
+	if err != nil {
+		return err
+	}
+	select {
+	case c.outboxWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// 发件箱发送协程，按顺序将发件箱中的消息加入发送队列，写入连接后提交
+func (c *Client) flushOutbox(ob *outbox.Outbox) {
+	ctx, q := c.ctx, c.queue
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		// 客户端停止时发送队列中的消息被丢弃，下次连接后重新发送
+		defer ob.Rewind()
+		for {
+			detached := c.detached.Load()
+			records, next, err := ob.Read(maxWriteBatch)
+			if err != nil {
+				log.Printf("读取发件箱失败: %v", err)
+				return
+			}
+			for _, rec := range records {
+				if err := q.pushWait(ctx, &sendItem{data: rec.Data}); err != nil {
+					return
+				}
+			}
+
+			if len(records) > 0 {
+				if err := c.Flush(ctx); err != nil {
+					if ctx.Err() != nil || errors.Is(err, ErrClosed) {
+						return
+					}
+					// 写入失败，从上次提交的位置重新发送
+					ob.Rewind()
+					continue
+				}
+				// 写出期间断线过，部分消息可能随失败的写入被丢弃，从上次提交的位置重新发送
+				if c.detached.Load() != detached {
+					ob.Rewind()
+					continue
+				}
+			}
+			if err := ob.Commit(next); err != nil {
+				log.Printf("提交发件箱失败: %v", err)
+			}
+			if ob.Unread() {
+				continue
+			}
+
+			select {
+			case <-c.outboxWake:
+			case <-ctx.Done():
+				return
+			case <-q.done:
+				return
+			}
+		}
+	}()
+}
+
+// 加入发送队列，队列已满时等待，不受队列策略影响
+func (q *sendQueue) pushWait(ctx context.Context, it *sendItem) error {
+	select {
+	case q.items <- it:
+		return nil
+	case <-q.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,519 @@
+// Package outbox 实现持久化的发件箱，断线或进程重启期间待发送的消息保存在磁盘上
+//
+// 发件箱是一个只追加的分段日志：记录依次追加到目录下的段文件中，当前段超过 SegmentSize 后
+// 创建新段；消费方按顺序读取并在发送成功后提交位置，已提交的段文件会被删除。
+//
+// 使用方式:
+//
+//	ob, err := outbox.Open("/var/lib/app/outbox", outbox.Config{MaxBytes: 64 << 20, MaxAge: 24 * time.Hour})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	defer ob.Close()
+//	client := fernqclient.NewClient("edge-1", fernqclient.WithOutbox(ob))
+package outbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认配置
+const (
+	DefaultSegmentSize = 4 << 20 // 默认的段文件大小
+)
+
+const (
+	segmentExt   = ".seg"    // 段文件扩展名
+	cursorFile   = "cursor"  // 已提交位置文件名
+	recordHeader = 4 + 4 + 8 // 记录头：长度、校验和、追加时间
+)
+
+// ErrClosed 发件箱已关闭
+var ErrClosed = errors.New("outbox: 发件箱已关闭")
+
+// Config 发件箱配置，零值可用
+type Config struct {
+	SegmentSize int64         // 单个段文件的大小上限，0 表示使用 DefaultSegmentSize
+	MaxBytes    int64         // 所有段文件的总大小上限，超过时丢弃最早的段，0 表示不限制
+	MaxAge      time.Duration // 记录的最长保存时间，读取时跳过过期记录，0 表示不限制
+	Sync        bool          // 每次追加和提交后调用 fsync，断电也不丢失，代价是写入延迟
+}
+
+// Stats 发件箱统计
+type Stats struct {
+	Pending int    // 尚未提交的记录数
+	Bytes   int64  // 段文件总大小
+	Dropped uint64 // 因超过 MaxBytes 而丢弃的记录数
+	Expired uint64 // 因超过 MaxAge 而跳过的记录数
+}
+
+// Record 读取到的一条记录
+type Record struct {
+	Data []byte    // 记录内容
+	Time time.Time // 追加时间
+}
+
+// 段文件，文件名为段内第一条记录的全局位置
+type segment struct {
+	base  int64    // 段起始的全局位置
+	size  int64    // 段文件大小
+	count int      // 段内的记录数
+	file  *os.File // 段文件
+}
+
+// 段结束的全局位置
+func (s *segment) end() int64 {
+	return s.base + s.size
+}
+
+// Outbox 持久化发件箱，并发安全
+type Outbox struct {
+	dir string
+	cfg Config
+
+	mu        sync.Mutex
+	segments  []*segment // 按位置排序，最后一个为当前追加的段
+	committed int64      // 已提交的全局位置，之前的记录不再读取
+	read      int64      // 下一次读取的全局位置
+	pending   int        // 已提交位置之后的记录数
+	dropped   uint64
+	expired   uint64
+	closed    bool
+}
+
+// Open 打开或创建发件箱目录，恢复上次未提交的记录
+// 参数:
+//   - dir: 发件箱目录，不存在时创建
+//   - cfg: 发件箱配置
+//
+// 返回值:
+//   - *Outbox: 发件箱
+//   - error: 目录或文件读写失败时返回错误
+//
+// 注意事项:
+//   - 进程崩溃导致的最后一条不完整记录会被截断
+//   - 同一目录同时只能被一个 Outbox 打开
+func Open(dir string, cfg Config) (_ *Outbox, err error) {
+	if cfg.SegmentSize <= 0 {
+		cfg.SegmentSize = DefaultSegmentSize
+	}
+	// 至少保留两个段，超过总大小上限时才能丢弃最早的段
+	if cfg.MaxBytes > 0 && cfg.SegmentSize > cfg.MaxBytes/2 {
+		cfg.SegmentSize = max(cfg.MaxBytes/2, 1)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("outbox: 创建目录失败: %w", err)
+	}
+
+	o := &Outbox{dir: dir, cfg: cfg}
+	// 出错时关闭已打开的段文件
+	defer func() {
+		if err != nil {
+			o.closeFiles()
+		}
+	}()
+	committed, err := o.loadCursor()
+	if err != nil {
+		return nil, err
+	}
+	if err := o.loadSegments(); err != nil {
+		return nil, err
+	}
+	if len(o.segments) == 0 {
+		if err := o.createSegment(committed); err != nil {
+			return nil, err
+		}
+	}
+
+	// 已提交位置不能早于第一个段，也不能超过最后一个段
+	first, last := o.segments[0], o.segments[len(o.segments)-1]
+	o.committed = min(max(committed, first.base), last.end())
+	o.read = o.committed
+	for _, s := range o.segments {
+		if s.end() <= o.committed {
+			continue
+		}
+		n, err := o.countFrom(s, max(s.base, o.committed))
+		if err != nil {
+			return nil, err
+		}
+		o.pending += n
+	}
+	o.compact()
+	return o, nil
+}
+
+// Append 追加一条记录
+func (o *Outbox) Append(data []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return ErrClosed
+	}
+
+	active := o.segments[len(o.segments)-1]
+	if active.size >= o.cfg.SegmentSize {
+		if err := o.createSegment(active.end()); err != nil {
+			return err
+		}
+		active = o.segments[len(o.segments)-1]
+	}
+
+	rec := make([]byte, recordHeader+len(data))
+	binary.BigEndian.PutUint32(rec[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(rec[8:16], uint64(time.Now().UnixNano()))
+	copy(rec[recordHeader:], data)
+	binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(rec[8:]))
+	if _, err := active.file.WriteAt(rec, active.size); err != nil {
+		return fmt.Errorf("outbox: 写入记录失败: %w", err)
+	}
+	if o.cfg.Sync {
+		if err := active.file.Sync(); err != nil {
+			return fmt.Errorf("outbox: 同步记录失败: %w", err)
+		}
+	}
+	active.size += int64(len(rec))
+	active.count++
+	o.pending++
+
+	o.enforceMaxBytes()
+	return nil
+}
+
+// Read 从上次读取的位置按顺序读取最多 n 条记录，并推进读取位置
+//
+// 返回值 next 为读取后的位置，记录处理完成后传给 Commit；没有新记录时返回空切片。
+// 超过 MaxAge 的记录被跳过，此时可能返回空切片但 next 前进。
+func (o *Outbox) Read(n int) (records []Record, next int64, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil, o.read, ErrClosed
+	}
+
+	now := time.Now()
+	for len(records) < n {
+		s := o.segmentAt(o.read)
+		if s == nil {
+			break
+		}
+		// 跳过段文件损坏造成的空洞
+		o.read = max(o.read, s.base)
+		rec, size, err := readRecord(s.file, o.read-s.base, s.size)
+		if err != nil {
+			return records, o.read, fmt.Errorf("outbox: 读取记录失败: %w", err)
+		}
+		o.read += size
+		if o.cfg.MaxAge > 0 && now.Sub(rec.Time) > o.cfg.MaxAge {
+			o.expired++
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, o.read, nil
+}
+
+// Unread 是否有尚未读取的记录
+func (o *Outbox) Unread() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.read < o.segments[len(o.segments)-1].end()
+}
+
+// Commit 提交 next 之前的记录，这些记录不再被读取，所在的段文件全部提交后删除
+func (o *Outbox) Commit(next int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return ErrClosed
+	}
+	if next <= o.committed {
+		return nil
+	}
+	next = min(next, o.segments[len(o.segments)-1].end())
+
+	for _, s := range o.segments {
+		if s.end() <= o.committed || s.base >= next {
+			continue
+		}
+		n, err := o.countRange(s, max(s.base, o.committed), min(s.end(), next))
+		if err != nil {
+			return err
+		}
+		o.pending -= n
+	}
+	o.committed = next
+	o.read = max(o.read, next)
+	if err := o.saveCursor(); err != nil {
+		return err
+	}
+	o.compact()
+	return nil
+}
+
+// Rewind 将读取位置退回已提交位置，已读取但未提交的记录会被再次读取
+func (o *Outbox) Rewind() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.read = o.committed
+}
+
+// Stats 返回发件箱统计
+func (o *Outbox) Stats() Stats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st := Stats{Pending: o.pending, Dropped: o.dropped, Expired: o.expired}
+	for _, s := range o.segments {
+		st.Bytes += s.size
+	}
+	return st
+}
+
+// Close 关闭发件箱，未提交的记录保留在磁盘上，下次 Open 时恢复
+func (o *Outbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+	return o.closeFiles()
+}
+
+// 关闭所有段文件
+func (o *Outbox) closeFiles() error {
+	var first error
+	for _, s := range o.segments {
+		if err := s.file.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// 查找全局位置 pos 之后第一条记录所在的段，没有记录时返回 nil
+func (o *Outbox) segmentAt(pos int64) *segment {
+	for _, s := range o.segments {
+		if pos < s.end() {
+			return s
+		}
+	}
+	return nil
+}
+
+// 删除已全部提交的段，保留当前追加的段
+func (o *Outbox) compact() {
+	for len(o.segments) > 1 && o.segments[0].end() <= o.committed {
+		o.removeSegment()
+	}
+}
+
+// 超过总大小上限时丢弃最早的段，其中未提交的记录计入丢弃数
+func (o *Outbox) enforceMaxBytes() {
+	if o.cfg.MaxBytes <= 0 {
+		return
+	}
+	for len(o.segments) > 1 {
+		var total int64
+		for _, s := range o.segments {
+			total += s.size
+		}
+		if total <= o.cfg.MaxBytes {
+			return
+		}
+		s := o.segments[0]
+		if s.end() > o.committed {
+			n, _ := o.countFrom(s, max(s.base, o.committed))
+			o.dropped += uint64(n)
+			o.pending -= n
+			o.committed = s.end()
+			o.read = max(o.read, o.committed)
+			o.saveCursor()
+		}
+		o.removeSegment()
+	}
+}
+
+// 删除最早的段
+func (o *Outbox) removeSegment() {
+	s := o.segments[0]
+	s.file.Close()
+	os.Remove(s.file.Name())
+	o.segments = o.segments[1:]
+}
+
+// 创建新段作为当前追加的段
+func (o *Outbox) createSegment(base int64) error {
+	f, err := os.OpenFile(o.segmentPath(base), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("outbox: 创建段文件失败: %w", err)
+	}
+	o.segments = append(o.segments, &segment{base: base, file: f})
+	return nil
+}
+
+// 段文件路径
+func (o *Outbox) segmentPath(base int64) string {
+	return filepath.Join(o.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// 打开目录下的所有段文件，截断最后一个段末尾不完整的记录
+func (o *Outbox) loadSegments() error {
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return fmt.Errorf("outbox: 读取目录失败: %w", err)
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(o.dir, name), os.O_RDWR, 0o644)
+		if err != nil {
+			return fmt.Errorf("outbox: 打开段文件失败: %w", err)
+		}
+		o.segments = append(o.segments, &segment{base: base, file: f})
+	}
+	sort.Slice(o.segments, func(i, j int) bool {
+		return o.segments[i].base < o.segments[j].base
+	})
+
+	for i, s := range o.segments {
+		info, err := s.file.Stat()
+		if err != nil {
+			return fmt.Errorf("outbox: 读取段文件失败: %w", err)
+		}
+		// 扫描出有效记录的长度
+		var valid int64
+		for {
+			_, size, err := readRecord(s.file, valid, info.Size())
+			if err != nil {
+				break
+			}
+			valid += size
+			s.count++
+		}
+		s.size = valid
+		if i == len(o.segments)-1 {
+			if err := s.file.Truncate(valid); err != nil {
+				return fmt.Errorf("outbox: 截断段文件失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// 统计段内从全局位置 from 开始的记录数
+func (o *Outbox) countFrom(s *segment, from int64) (int, error) {
+	return o.countRange(s, from, s.end())
+}
+
+// 统计段内全局位置 [from, to) 之间的记录数
+func (o *Outbox) countRange(s *segment, from, to int64) (int, error) {
+	if from == s.base && to == s.end() {
+		return s.count, nil
+	}
+	n := 0
+	for pos := from - s.base; pos < to-s.base; n++ {
+		size, err := readRecordSize(s.file, pos)
+		if err != nil {
+			return n, fmt.Errorf("outbox: 读取记录失败: %w", err)
+		}
+		pos += size
+	}
+	return n, nil
+}
+
+// 读取已提交位置，文件不存在时返回 0
+//
+// 保存时崩溃留下的临时文件直接删除，提交位置退回上一次成功保存的值，之后的记录会被再次读取
+func (o *Outbox) loadCursor() (int64, error) {
+	os.Remove(filepath.Join(o.dir, cursorFile+".tmp"))
+	data, err := os.ReadFile(filepath.Join(o.dir, cursorFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("outbox: 读取提交位置失败: %w", err)
+	}
+	if len(data) != 8 {
+		return 0, fmt.Errorf("outbox: 提交位置文件损坏")
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// 保存已提交位置，先写临时文件再重命名，避免写入一半时崩溃
+func (o *Outbox) saveCursor() error {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], uint64(o.committed))
+	tmp := filepath.Join(o.dir, cursorFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("outbox: 保存提交位置失败: %w", err)
+	}
+	_, err = f.Write(data[:])
+	if err == nil && o.cfg.Sync {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(o.dir, cursorFile))
+	}
+	if err != nil {
+		return fmt.Errorf("outbox: 保存提交位置失败: %w", err)
+	}
+	return nil
+}
+
+// 读取段内偏移 off 处的记录，返回记录及其占用的字节数，limit 为段内有效数据的长度
+func readRecord(f *os.File, off, limit int64) (Record, int64, error) {
+	var header [recordHeader]byte
+	if off+recordHeader > limit {
+		return Record{}, 0, io.ErrUnexpectedEOF
+	}
+	if _, err := f.ReadAt(header[:], off); err != nil {
+		return Record{}, 0, err
+	}
+	n := binary.BigEndian.Uint32(header[0:4])
+	if off+recordHeader+int64(n) > limit {
+		return Record{}, 0, io.ErrUnexpectedEOF
+	}
+	body := make([]byte, 8+int(n))
+	copy(body, header[8:])
+	if _, err := f.ReadAt(body[8:], off+recordHeader); err != nil {
+		return Record{}, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+		return Record{}, 0, io.ErrUnexpectedEOF
+	}
+	return Record{
+		Data: body[8:],
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16]))),
+	}, recordHeader + int64(n), nil
+}
+
+// 读取段内偏移 off 处记录占用的字节数，不校验内容
+func readRecordSize(f *os.File, off int64) (int64, error) {
+	var header [4]byte
+	if _, err := f.ReadAt(header[:], off); err != nil {
+		return 0, err
+	}
+	return recordHeader + int64(binary.BigEndian.Uint32(header[:])), nil
+}
@@ -0,0 +1,297 @@
+package outbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// 打开发件箱，测试结束时关闭
+func open(t *testing.T, dir string, cfg Config) *Outbox {
+	t.Helper()
+	o, err := Open(dir, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+// 追加 msg-from 到 msg-(to-1)，每条记录 16 字节
+func appendRange(t *testing.T, o *Outbox, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		if err := o.Append([]byte(fmt.Sprintf("msg-%012d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 读取全部未读记录并检查内容依次为 msg-from 到 msg-(to-1)，返回读取后的位置
+func expectRange(t *testing.T, o *Outbox, from, to int) int64 {
+	t.Helper()
+	records, next, err := o.Read(to - from + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != to-from {
+		t.Fatalf("读取到 %d 条记录，期望 %d 条", len(records), to-from)
+	}
+	for i, rec := range records {
+		if want := fmt.Sprintf("msg-%012d", from+i); string(rec.Data) != want {
+			t.Fatalf("第 %d 条记录为 %q，期望 %q", i, rec.Data, want)
+		}
+	}
+	return next
+}
+
+// 段文件列表
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// 追加、读取、提交后关闭，重新打开时从已提交位置继续
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Config{})
+	appendRange(t, o, 0, 5)
+	records, next, err := o.Read(2)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Read = %d, %v", len(records), err)
+	}
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	// 已读取但未提交的记录在重新打开后再次读取
+	expectRange(t, o, 2, 5)
+	if o.Unread() {
+		t.Fatal("读取全部记录后 Unread 为 true")
+	}
+	o.Close()
+	if err := o.Append(nil); err != ErrClosed {
+		t.Fatalf("关闭后 Append 返回 %v，期望 ErrClosed", err)
+	}
+
+	o = open(t, dir, Config{})
+	if st := o.Stats(); st.Pending != 3 {
+		t.Fatalf("重新打开后有 %d 条未提交记录，期望 3 条", st.Pending)
+	}
+	next = expectRange(t, o, 2, 5)
+
+	// Rewind 后从已提交位置再次读取
+	o.Rewind()
+	expectRange(t, o, 2, 5)
+	appendRange(t, o, 5, 6)
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	if st := o.Stats(); st.Pending != 1 {
+		t.Fatalf("提交后有 %d 条未提交记录，期望 1 条", st.Pending)
+	}
+	expectRange(t, o, 5, 6)
+}
+
+// 进程崩溃留下的不完整或校验失败的最后一条记录在打开时被截断，之后可以继续追加
+func TestTornTail(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(data []byte) []byte
+	}{
+		{"partial header", func(data []byte) []byte { return data[:len(data)-32+5] }},
+		{"partial body", func(data []byte) []byte { return data[:len(data)-3] }},
+		{"bad checksum", func(data []byte) []byte { data[len(data)-1] ^= 0xFF; return data }},
+		{"garbage", func(data []byte) []byte { return append(data, 0xFF, 0xFF, 0xFF) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			o := open(t, dir, Config{})
+			appendRange(t, o, 0, 3)
+			o.Close()
+
+			files := segmentFiles(t, dir)
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(files[0], tt.corrupt(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			o = open(t, dir, Config{})
+			want := 2
+			if tt.name == "garbage" {
+				want = 3
+			}
+			if st := o.Stats(); st.Pending != want || st.Bytes != int64(want*32) {
+				t.Fatalf("打开后 %d 条记录 %d 字节，期望 %d 条 %d 字节", st.Pending, st.Bytes, want, want*32)
+			}
+			appendRange(t, o, want, want+1)
+			expectRange(t, o, 0, want+1)
+		})
+	}
+}
+
+// 保存提交位置时崩溃留下的临时文件被忽略，提交位置退回上一次成功保存的值
+func TestCursorRecovery(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Config{})
+	appendRange(t, o, 0, 4)
+	records, next, err := o.Read(2)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Read = %d, %v", len(records), err)
+	}
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	o.Close()
+
+	tmp := filepath.Join(dir, cursorFile+".tmp")
+	if err := os.WriteFile(tmp, []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	o = open(t, dir, Config{})
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatalf("临时文件未删除: %v", err)
+	}
+	expectRange(t, o, 2, 4)
+	o.Close()
+
+	// 提交位置文件损坏时打开失败
+	if err := os.WriteFile(filepath.Join(dir, cursorFile), []byte{1, 2, 3}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(dir, Config{}); err == nil {
+		t.Fatal("提交位置文件损坏时打开成功")
+	}
+}
+
+// 当前进程打开的文件数，不支持时跳过测试
+func openFiles(t *testing.T) int {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("需要 /proc/self/fd")
+	}
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip(err)
+	}
+	return len(entries)
+}
+
+// 段文件无法读取时打开失败，已打开的段文件被关闭
+func TestOpenErrorClosesFiles(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Config{SegmentSize: 64})
+	appendRange(t, o, 0, 8)
+	o.Close()
+	if n := len(segmentFiles(t, dir)); n < 3 {
+		t.Fatalf("只有 %d 个段文件", n)
+	}
+	// 最后一个段替换为失效的符号链接，打开它时前面的段已打开
+	files := segmentFiles(t, dir)
+	last := files[len(files)-1]
+	os.Remove(last)
+	if err := os.Symlink(filepath.Join(dir, "missing"), last); err != nil {
+		t.Skip(err)
+	}
+
+	before := openFiles(t)
+	if _, err := Open(dir, Config{SegmentSize: 64}); err == nil {
+		t.Fatal("段文件无法打开时打开成功")
+	}
+	if after := openFiles(t); after > before {
+		t.Fatalf("打开失败后文件描述符从 %d 增加到 %d", before, after)
+	}
+}
+
+// 超过 MaxBytes 时丢弃最早的段，其中未提交的记录计入丢弃数
+func TestMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	// 每条记录 32 字节，段大小被限制为 MaxBytes/2 即 4 条记录
+	o := open(t, dir, Config{MaxBytes: 256})
+	appendRange(t, o, 0, 20)
+
+	st := o.Stats()
+	if st.Bytes > 256 {
+		t.Fatalf("段文件共 %d 字节，超过上限 256", st.Bytes)
+	}
+	if int(st.Dropped)+st.Pending != 20 || st.Dropped == 0 {
+		t.Fatalf("丢弃 %d 条，未提交 %d 条，期望合计 20 条且有丢弃", st.Dropped, st.Pending)
+	}
+	expectRange(t, o, int(st.Dropped), 20)
+	if n := len(segmentFiles(t, dir)); n > 2 {
+		t.Fatalf("保留了 %d 个段文件", n)
+	}
+
+	// 丢弃推进了提交位置，重新打开后不会再读到被丢弃的记录
+	o.Close()
+	o = open(t, dir, Config{MaxBytes: 256})
+	expectRange(t, o, int(st.Dropped), 20)
+}
+
+// 超过 MaxAge 的记录在读取时跳过
+func TestMaxAge(t *testing.T) {
+	o := open(t, t.TempDir(), Config{MaxAge: 50 * time.Millisecond})
+	appendRange(t, o, 0, 2)
+	time.Sleep(100 * time.Millisecond)
+	appendRange(t, o, 2, 3)
+
+	next := expectRange(t, o, 2, 3)
+	if st := o.Stats(); st.Expired != 2 {
+		t.Fatalf("跳过 %d 条过期记录，期望 2 条", st.Expired)
+	}
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	if st := o.Stats(); st.Pending != 0 {
+		t.Fatalf("提交后有 %d 条未提交记录，期望 0 条", st.Pending)
+	}
+}
+
+// 全部提交的段文件被删除，只保留当前追加的段
+func TestCompaction(t *testing.T) {
+	dir := t.TempDir()
+	o := open(t, dir, Config{SegmentSize: 64})
+	appendRange(t, o, 0, 10)
+	if n := len(segmentFiles(t, dir)); n != 5 {
+		t.Fatalf("追加后有 %d 个段文件，期望 5 个", n)
+	}
+
+	// 提交到第二个段中间，只删除第一个段
+	records, next, err := o.Read(3)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Read = %d, %v", len(records), err)
+	}
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(segmentFiles(t, dir)); n != 4 {
+		t.Fatalf("提交 3 条后有 %d 个段文件，期望 4 个", n)
+	}
+
+	next = expectRange(t, o, 3, 10)
+	if err := o.Commit(next); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(segmentFiles(t, dir)); n != 1 {
+		t.Fatalf("全部提交后有 %d 个段文件，期望 1 个", n)
+	}
+	if st := o.Stats(); st.Pending != 0 {
+		t.Fatalf("全部提交后有 %d 条未提交记录", st.Pending)
+	}
+
+	// 压缩后重新打开，新记录从已提交位置继续
+	o.Close()
+	o = open(t, dir, Config{SegmentSize: 64})
+	appendRange(t, o, 10, 12)
+	expectRange(t, o, 10, 12)
+}
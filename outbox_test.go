@@ -0,0 +1,99 @@
+package fernqclient_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/outbox"
+)
+
+// 未连接时写入发件箱的消息在重新打开发件箱并连接后按顺序发送
+func TestOutboxReplay(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv")
+	dir := t.TempDir()
+
+	ob, err := outbox.Open(dir, outbox.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offline := fernqclient.NewClient("edge", fernqclient.WithOutbox(ob))
+	for i := 0; i < 3; i++ {
+		if err := offline.Send("recv", []byte(fmt.Sprintf("msg-%d", i))); err != nil {
+			t.Fatalf("未连接时发送: %v", err)
+		}
+	}
+	if err := ob.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟进程重启
+	ob, err = outbox.Open(dir, outbox.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ob.Close()
+	if n := ob.Stats().Pending; n != 3 {
+		t.Fatalf("重新打开后有 %d 条未提交记录，期望 3 条", n)
+	}
+	connect(t, s, "edge", fernqclient.WithOutbox(ob))
+
+	for i := 0; i < 3; i++ {
+		want := fmt.Sprintf("msg-%d", i)
+		if msg := receive(t, recv); msg.From != "edge" || string(msg.Message) != want {
+			t.Fatalf("收到 %s: %q，期望 edge: %q", msg.From, msg.Message, want)
+		}
+	}
+	deadline := time.Now().Add(testTimeout)
+	for ob.Stats().Pending != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("发送后仍有 %d 条未提交记录", ob.Stats().Pending)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Stop 之后发送返回 ErrClosed，再次连接后恢复发送
+func TestOutboxAfterStop(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv")
+	ob, err := outbox.Open(t.TempDir(), outbox.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ob.Close()
+
+	edge := fernqclient.NewClient("edge", fernqclient.WithOutbox(ob))
+	if err := edge.Send("recv", []byte("before connect")); err != nil {
+		t.Fatalf("Connect 之前发送: %v", err)
+	}
+	if err := edge.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, recv); string(msg.Message) != "before connect" {
+		t.Fatalf("收到 %q", msg.Message)
+	}
+	edge.Stop()
+	if err := edge.Send("recv", []byte("after stop")); !errors.Is(err, fernqclient.ErrClosed) {
+		t.Fatalf("Stop 之后发送返回 %v，期望 ErrClosed", err)
+	}
+	if n := ob.Stats().Pending; n != 0 {
+		t.Fatalf("Stop 之后发件箱有 %d 条记录", n)
+	}
+
+	// 等待服务器移除旧连接后再次连接
+	waitLeft(t, s, "edge")
+	if err := edge.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer edge.Stop()
+	if err := edge.Send("recv", []byte("reconnected")); err != nil {
+		t.Fatalf("再次连接后发送: %v", err)
+	}
+	if msg := receive(t, recv); string(msg.Message) != "reconnected" {
+		t.Fatalf("收到 %q", msg.Message)
+	}
+}
@@ -0,0 +1,89 @@
+package fernqclient
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// ReconnectPolicy 断线重连策略，采用带随机抖动的指数退避
+type ReconnectPolicy struct {
+	InitialDelay time.Duration // 首次重连前的等待时间
+	MaxDelay     time.Duration // 等待时间上限
+	Multiplier   float64       // 每次失败后等待时间的增长倍数
+	Jitter       float64       // 随机抖动比例，0.2 表示在 ±20% 范围内波动
+	MaxAttempts  int           // 最大连续重连次数，<= 0 表示不限次数
+}
+
+// DefaultReconnectPolicy 默认重连策略：500ms 起步，每次翻倍，最长 30s，±20% 抖动，不限次数
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	MaxAttempts:  0,
+}
+
+// WithReconnect 开启断线重连
+//
+// 连接意外断开后（服务器重启、网络中断等），客户端按策略退避后重新连接并重新完成房间验证，
+// 期间 Read() 返回的通道保持打开，重连成功后继续接收消息。
+// 超过最大重连次数或调用 Stop() 后，通道才会被关闭。
+//
+// 注意事项:
+//   - 断开时尚未收到响应的请求会立即返回 ErrConnectionLost，不会在重连后重发
+//   - 断开期间 Send、Broadcast、ScanSend、UserScanSingle 与 SendReliable 的消息保留在发送队列中，重连成功后按顺序发送
+//   - 断开期间 Request、RequestAny、RequestAll、SendConfirmed 与 ScanSendConfirmed 不进入发送队列，
+//     立即返回 ErrNotConnected，调用方可在重连后重试；处理函数的响应同样不会保留
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(o *options) {
+		o.reconnect = &policy
+	}
+}
+
+// 计算第 attempt 次重连前的等待时间，attempt 从 1 开始
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < float64(p.MaxDelay)); i++ {
+		if p.Multiplier > 1 {
+			delay *= p.Multiplier
+		}
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	}
+	return time.Duration(delay)
+}
+
+// 按策略重新连接，成功时返回继续读取新连接的解码器
+func (c *Client) reconnect() (*codec.Decoder, error) {
+	policy := c.opts.reconnect
+	for attempt := 1; policy.MaxAttempts <= 0 || attempt <= policy.MaxAttempts; attempt++ {
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return nil, ErrClosed
+		case <-timer.C:
+		}
+
+		conn, dec, err := c.connectEndpoints(c.ctx)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return nil, ErrClosed
+			}
+			log.Printf("第 %d 次重连失败: %v", attempt, err)
+			c.setState(StateReconnecting, err)
+			continue
+		}
+		c.attach(conn)
+		return dec, nil
+	}
+	return nil, fmt.Errorf("%w %d", ErrReconnectLimit, policy.MaxAttempts)
+}
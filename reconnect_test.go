@@ -0,0 +1,152 @@
+package fernqclient_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 重连间隔较短的策略
+func fastReconnect() fernqclient.ReconnectPolicy {
+	p := fernqclient.DefaultReconnectPolicy
+	p.InitialDelay = 20 * time.Millisecond
+	p.MaxDelay = 100 * time.Millisecond
+	return p
+}
+
+// 返回一个在客户端重新进入已连接状态时收到通知的通道
+func reconnected(c *fernqclient.Client) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	c.OnStateChange(func(ev fernqclient.StateEvent) {
+		if ev.From != fernqclient.StateClosed && ev.To == fernqclient.StateConnected {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	})
+	return ch
+}
+
+// 断线重连后 Read() 返回的通道保持打开并继续接收消息
+func TestReconnectKeepsRead(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv", fernqclient.WithReconnect(fastReconnect()))
+	sender := connect(t, s, "sender", fernqclient.WithReconnect(fastReconnect()))
+	ch := recv.Read()
+
+	if err := sender.Send("recv", []byte("before")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, recv); string(msg.Message) != "before" {
+		t.Fatalf("收到 %q，期望 before", msg.Message)
+	}
+
+	recvBack, senderBack := reconnected(recv), reconnected(sender)
+	s.CloseClientConnections()
+	for _, back := range []<-chan struct{}{recvBack, senderBack} {
+		select {
+		case <-back:
+		case <-time.After(testTimeout):
+			t.Fatal("等待重连超时")
+		}
+	}
+
+	if err := sender.Send("recv", []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			t.Fatal("重连后接收通道被关闭")
+		}
+		if string(msg.Message) != "after" {
+			t.Fatalf("收到 %q，期望 after", msg.Message)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("重连后等待消息超时")
+	}
+}
+
+// 可以模拟网络中断的 TCP 传输方式
+type gateTransport struct {
+	mu    sync.Mutex
+	down  bool
+	conns []net.Conn
+}
+
+func (g *gateTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.down {
+		return nil, errors.New("网络不可用")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err == nil {
+		g.conns = append(g.conns, conn)
+	}
+	return conn, err
+}
+
+// 中断时断开已建立的连接，之后的连接失败；恢复后可以重新连接
+func (g *gateTransport) setDown(down bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.down = down
+	if down {
+		for _, conn := range g.conns {
+			conn.Close()
+		}
+		g.conns = nil
+	}
+}
+
+// 等待客户端进入指定状态
+func waitState(t *testing.T, c *fernqclient.Client, want fernqclient.State) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for c.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s 处于 %s，等待 %s 超时", c.ClientName, c.State(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 断线重连期间普通发送进入发送队列，请求与确认发送立即返回 ErrNotConnected
+func TestReconnectSendWhileDisconnected(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv")
+	gate := &gateTransport{}
+	sender := connect(t, s, "sender", fernqclient.WithReconnect(fastReconnect()), fernqclient.WithTransport(gate))
+
+	gate.setDown(true)
+	waitState(t, sender, fernqclient.StateReconnecting)
+
+	if err := sender.Send("recv", []byte("queued")); err != nil {
+		t.Fatalf("断开期间 Send 返回 %v，期望进入发送队列", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if _, err := sender.Request(ctx, "recv", "/echo", nil); !errors.Is(err, fernqclient.ErrNotConnected) {
+		t.Fatalf("断开期间 Request 返回 %v，期望 ErrNotConnected", err)
+	}
+	if _, err := sender.RequestAll(ctx, "recv", "/echo", nil); !errors.Is(err, fernqclient.ErrNotConnected) {
+		t.Fatalf("断开期间 RequestAll 返回 %v，期望 ErrNotConnected", err)
+	}
+	if _, err := sender.SendConfirmed(ctx, "recv", []byte("confirmed")); !errors.Is(err, fernqclient.ErrNotConnected) {
+		t.Fatalf("断开期间 SendConfirmed 返回 %v，期望 ErrNotConnected", err)
+	}
+
+	gate.setDown(false)
+	waitState(t, sender, fernqclient.StateConnected)
+	if msg := receive(t, recv); string(msg.Message) != "queued" {
+		t.Fatalf("收到 %q，期望 queued", msg.Message)
+	}
+}
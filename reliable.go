@@ -0,0 +1,252 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// ReliablePolicy 可靠投递策略
+type ReliablePolicy struct {
+	// Retransmit 未收到确认时的重传退避策略，MaxAttempts 为最多发送次数，<= 0 表示重传直到 ctx 结束
+	Retransmit ReconnectPolicy
+	// DedupWindow 接收方为每个发送方记录的最近消息id数，窗口内重复收到的消息只确认不投递
+	DedupWindow int
+}
+
+// DefaultReliablePolicy 默认可靠投递策略：1s 后首次重传，每次翻倍，最长 30s，±20% 抖动，
+// 重传直到 ctx 结束；每个发送方记录最近 1024 条消息id
+var DefaultReliablePolicy = ReliablePolicy{
+	Retransmit: ReconnectPolicy{
+		InitialDelay: time.Second,
+		MaxDelay:     30 * time.Second,
+		Multiplier:   2,
+		Jitter:       0.2,
+		MaxAttempts:  0,
+	},
+	DedupWindow: 1024,
+}
+
+// WithReliable 开启可靠投递的接收端，并设置 SendReliable 使用的策略
+//
+// 开启后，收到 SendReliable 发送的消息时自动回复确认，并在去重窗口内丢弃重传的重复消息，
+// 投递给 Read() 或回调的是去掉信封后的原始内容，FernqMessage.ID 为消息id。
+//
+// 注意事项:
+//   - 接收方必须开启，否则收到的是带信封的原始数据且不会回复确认，发送方会一直重传
+//   - 由 OnMessage 等回调处理的消息在回调正常返回后才回复确认；回调 panic、按溢出策略被丢弃
+//     或 Stop() 时尚未执行的消息不确认，由发送方重传。需要保证每条消息至少处理一次时应使用回调
+//   - 由 Read() 接收的消息在进入通道时即回复确认，客户端无法得知消费方何时处理完：
+//     消费方取出后处理失败、或 Stop() 后未读完通道中剩余的消息即退出，消息会丢失
+//   - 同一消息仍在回调中处理时收到的重传被忽略，处理完成后统一确认
+//   - 去重窗口只在进程内有效，接收方重启后可能再次收到已处理的消息，处理逻辑应幂等
+func WithReliable(policy ReliablePolicy) Option {
+	return func(o *options) {
+		o.reliable = &policy
+	}
+}
+
+// SendReliable 至少一次投递的 P2P 发送，阻塞直到收到接收方的确认
+// 参数:
+//   - ctx: 上下文，用于取消等待或设置超时
+//   - to: 目标客户端名称，需开启 WithReliable
+//   - message: 消息内容
+//
+// 返回值:
+//   - error: 达到最多发送次数仍未确认时返回 ErrNotAcknowledged；
+//     以及客户端停止、上下文取消或超时
+//
+// 注意事项:
+//   - 未收到确认时按 ReliablePolicy.Retransmit 退避重传，重传使用相同的消息id，接收方据此去重
+//   - 断线重连期间重传的消息保留在发送队列中，重连成功后继续发送；
+//     未开启 WithReconnect 时连接断开即返回错误
+//   - 返回错误不代表对方一定未收到，可能仅是确认丢失
+//
+// 使用方式:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//	if err := client.SendReliable(ctx, "worker-1", task); err != nil {
+//	    // 记录失败的任务，稍后重新派发
+//	}
+func (c *Client) SendReliable(ctx context.Context, to string, message []byte) error {
+	policy := DefaultReliablePolicy
+	if c.opts.reliable != nil {
+		policy = *c.opts.reliable
+	}
+
+	id := uuid.New()
+	envelope := codec.AppendEnvelope(nil, codec.EnvelopeData, id, message)
+
+	acked := make(chan struct{})
+	c.pendingMu.Lock()
+	if c.acks == nil {
+		c.acks = make(map[uuid.UUID]pendingAck)
+	}
+	c.acks[id] = pendingAck{to: to, done: acked}
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.acks, id)
+		c.pendingMu.Unlock()
+	}()
+
+	retransmit := &policy.Retransmit
+	for attempt := 1; ; attempt++ {
+		// 发送队列已满时等待下次重传
+		// 重传由本方法负责，不经过发件箱
+		err := c.writeFrame(func(dst []byte) []byte {
+			return codec.AppendP2PRelay(dst, to, envelope)
+		})
+		if err != nil && !errors.Is(err, ErrSendQueueFull) {
+			return err
+		}
+
+		timer := time.NewTimer(retransmit.backoff(attempt))
+		select {
+		case <-acked:
+			timer.Stop()
+			return nil
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if retransmit.MaxAttempts > 0 && attempt >= retransmit.MaxAttempts {
+			return ErrNotAcknowledged
+		}
+	}
+}
+
+// 等待确认的可靠消息
+type pendingAck struct {
+	to   string        // 目标客户端名称，只接受来自目标的确认
+	done chan struct{} // 收到确认时关闭
+}
+
+// 将确认交给等待中的发送方，message 是确认信封时返回 true
+func (c *Client) dispatchAck(from string, message []byte) bool {
+	kind, id, _, ok := codec.ParseEnvelope(message)
+	if !ok || kind != codec.EnvelopeAck {
+		return false
+	}
+
+	c.pendingMu.Lock()
+	pending, ok := c.acks[id]
+	// 来自其他客户端的确认视为伪造，保留等待项
+	if ok && pending.to != from {
+		ok = false
+	}
+	if ok {
+		delete(c.acks, id)
+	}
+	c.pendingMu.Unlock()
+	// 重传导致的重复确认、发送方已放弃或伪造的确认时忽略
+	if ok {
+		close(pending.done)
+	}
+	return true
+}
+
+// 处理可靠投递的数据消息，message 不是数据信封时返回 false
+func (c *Client) receiveReliable(closer *connCloser, msg FernqMessage) (bool, error) {
+	kind, id, payload, ok := codec.ParseEnvelope(msg.Message)
+	if !ok || kind != codec.EnvelopeData {
+		return false, nil
+	}
+	from := msg.From
+
+	c.dedupMu.Lock()
+	window := c.dedupWindow(from)
+	done, seen := window.seen[id]
+	if !seen {
+		window.add(id)
+	}
+	c.dedupMu.Unlock()
+	if seen {
+		// 已处理完成时之前的确认可能丢失，重新确认；仍在处理中时等处理完成后确认
+		if done {
+			c.sendAck(from, id)
+		}
+		return true, nil
+	}
+
+	msg.Message = payload
+	msg.ID = uuid.UUID(id).String()
+	accepted, err := c.route(closer, msg, func(ok bool) {
+		c.finishReliable(from, id, ok)
+	})
+	if !accepted {
+		c.finishReliable(from, id, false)
+	}
+	return true, err
+}
+
+// 可靠消息处理结束，成功时标记完成并回复确认，失败时移出去重窗口以便处理重传
+func (c *Client) finishReliable(from string, id [16]byte, ok bool) {
+	c.dedupMu.Lock()
+	if w := c.dedup[from]; w != nil {
+		if _, seen := w.seen[id]; seen {
+			if ok {
+				w.seen[id] = true
+			} else {
+				delete(w.seen, id)
+			}
+		}
+	}
+	c.dedupMu.Unlock()
+	if ok {
+		c.sendAck(from, id)
+	}
+}
+
+// 向发送方回复确认，失败时由发送方重传
+func (c *Client) sendAck(to string, id [16]byte) {
+	var envelope [64]byte
+	ack := codec.AppendEnvelope(envelope[:0], codec.EnvelopeAck, id, nil)
+	c.writeFrame(func(dst []byte) []byte {
+		return codec.AppendP2PRelay(dst, to, ack)
+	})
+}
+
+// 获取发送方的去重窗口，调用方需持有 dedupMu
+func (c *Client) dedupWindow(from string) *dedupWindow {
+	w, ok := c.dedup[from]
+	if !ok {
+		if c.dedup == nil {
+			c.dedup = make(map[string]*dedupWindow)
+		}
+		size := c.opts.reliable.DedupWindow
+		if size <= 0 {
+			size = DefaultReliablePolicy.DedupWindow
+		}
+		w = &dedupWindow{
+			ids:  make([][16]byte, 0, size),
+			seen: make(map[[16]byte]bool, size),
+		}
+		c.dedup[from] = w
+	}
+	return w
+}
+
+// 去重窗口，记录最近收到的消息id，满时淘汰最早的记录
+type dedupWindow struct {
+	ids  [][16]byte        // 环形缓冲
+	next int               // 窗口已满时下一个被覆盖的位置
+	seen map[[16]byte]bool // 窗口内的消息id，值表示是否已处理完成并确认
+}
+
+// 记录处理中的消息id
+func (w *dedupWindow) add(id [16]byte) {
+	if len(w.ids) < cap(w.ids) {
+		w.ids = append(w.ids, id)
+	} else {
+		delete(w.seen, w.ids[w.next])
+		w.ids[w.next] = id
+		w.next = (w.next + 1) % len(w.ids)
+	}
+	w.seen[id] = false
+}
@@ -0,0 +1,118 @@
+package fernqclient_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 重传间隔较短的可靠投递策略
+func fastReliable() fernqclient.ReliablePolicy {
+	p := fernqclient.DefaultReliablePolicy
+	p.Retransmit.InitialDelay = 50 * time.Millisecond
+	p.Retransmit.MaxDelay = 100 * time.Millisecond
+	return p
+}
+
+// 回调 panic 时不确认，发送方重传后再次处理
+func TestReliableAckAfterHandler(t *testing.T) {
+	s := newServer(t)
+	recv := fernqclient.NewClient("recv", fernqclient.WithReliable(fastReliable()))
+	var calls atomic.Int32
+	recv.OnMessage(func(ctx context.Context, msg fernqclient.FernqMessage) {
+		if calls.Add(1) == 1 {
+			panic("第一次处理失败")
+		}
+	})
+	if err := recv.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer recv.Stop()
+	sender := connect(t, s, "sender", fernqclient.WithReliable(fastReliable()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := sender.SendReliable(ctx, "recv", []byte("task")); err != nil {
+		t.Fatalf("SendReliable: %v", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("回调执行 %d 次，期望 2 次", n)
+	}
+}
+
+// 处理期间收到的重传和处理完成后的重传都不会重复执行回调
+func TestReliableDedup(t *testing.T) {
+	s := newServer(t)
+	recv := fernqclient.NewClient("recv", fernqclient.WithReliable(fastReliable()))
+	var calls atomic.Int32
+	recv.OnMessage(func(ctx context.Context, msg fernqclient.FernqMessage) {
+		calls.Add(1)
+		// 处理时间超过多个重传间隔
+		time.Sleep(300 * time.Millisecond)
+	})
+	if err := recv.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer recv.Stop()
+	sender := connect(t, s, "sender", fernqclient.WithReliable(fastReliable()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		if err := sender.SendReliable(ctx, "recv", []byte("task")); err != nil {
+			t.Fatalf("SendReliable: %v", err)
+		}
+	}
+	// 等待可能迟到的重传被处理
+	time.Sleep(200 * time.Millisecond)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("回调执行 %d 次，期望每条消息 1 次共 2 次", n)
+	}
+}
+
+// 只接受来自目标客户端的确认，其他客户端伪造的确认被忽略
+func TestReliableForgedAck(t *testing.T) {
+	s := newServer(t)
+	// 接收方不开启 WithReliable，收到带信封的原始数据且不自动确认
+	recv := connect(t, s, "recv")
+	forger := connect(t, s, "forger")
+	sender := connect(t, s, "sender", fernqclient.WithReliable(fastReliable()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- sender.SendReliable(ctx, "recv", []byte("task"))
+	}()
+
+	kind, id, _, ok := codec.ParseEnvelope(receive(t, recv).Message)
+	if !ok || kind != codec.EnvelopeData {
+		t.Fatal("收到的不是数据信封")
+	}
+	ack := codec.AppendEnvelope(nil, codec.EnvelopeAck, id, nil)
+
+	if err := forger.Send("sender", ack); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("伪造的确认使 SendReliable 返回 %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := recv.Send("sender", ack); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("SendReliable: %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("目标的确认未被接受")
+	}
+}
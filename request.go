@@ -0,0 +1,176 @@
+package fernqclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 请求的响应结果
+type Response struct {
+	From   string           // 响应方的客户端名称
+	Status codec.StatusCode // 响应状态码
+	Body   []byte           // 响应体
+}
+
+// Request 请求/响应模式，向指定目标发送请求并阻塞等待响应
+// 参数:
+//   - ctx: 上下文，用于取消请求或设置超时
+//   - target: 目标客户端名称
+//   - url: 请求地址，由目标客户端按地址分发处理
+//   - body: 请求体
+//
+// 返回值:
+//   - *Response: 目标客户端返回的响应
+//   - error: 请求过程中的错误（包括发送失败、上下文取消或超时、连接断开 ErrConnectionLost）；
+//     非 2xx 的状态码不视为错误，可通过 Response.Err() 检查
+//
+// 使用方式:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+//	defer cancel()
+//	resp, err := client.Request(ctx, "target-client", "/user/info", []byte("alice"))
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Println(resp.Status, string(resp.Body))
+func (c *Client) Request(ctx context.Context, target, url string, body []byte) (*Response, error) {
+	id, data, err := codec.CreateRequestMessage(target, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求消息失败: %w", err)
+	}
+	return c.roundTrip(ctx, id, data)
+}
+
+// RequestAny 扫描请求模式(属于单播模式)，向正则表达式匹配的用户中随机一个发送请求并阻塞等待响应
+// 参数:
+//   - ctx: 上下文，用于取消请求或设置超时
+//   - pattern: 用于匹配目标用户的正则表达式
+//   - url: 请求地址
+//   - body: 请求体
+//
+// 返回值:
+//   - *Response: 实际处理请求的客户端返回的响应，Response.From 为该客户端名称
+//   - error: 请求过程中的错误（包括正则表达式无效、发送失败、上下文取消或超时、连接断开）
+//
+// 使用方式:
+//
+//	// 在所有 worker 中随机选择一个处理任务，实现负载均衡
+//	resp, err := client.RequestAny(ctx, "worker-[0-9]+", "/task/run", payload)
+//	if err != nil {
+//	    return err
+//	}
+//	fmt.Printf("由 %s 处理: %d\n", resp.From, resp.Status)
+func (c *Client) RequestAny(ctx context.Context, pattern, url string, body []byte) (*Response, error) {
+	// 验证正则表达式有效性
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("无效的正则表达式 '%s': %w", pattern, err)
+	}
+
+	id, data, err := codec.CreateRequestMessageScan(pattern, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建扫描请求消息失败: %w", err)
+	}
+	return c.roundTrip(ctx, id, data)
+}
+
+// 等待响应的请求
+type pendingCall struct {
+	ch    chan *Response // 响应通道
+	done  chan struct{}  // 等待方退出时关闭，仅多响应请求使用
+	multi bool           // 是否接收多个响应
+}
+
+// 注册等待响应的请求
+func (c *Client) addPending(id string, call *pendingCall) {
+	c.pendingMu.Lock()
+	if c.pending == nil {
+		c.pending = make(map[string]*pendingCall)
+	}
+	c.pending[id] = call
+	c.pendingMu.Unlock()
+}
+
+// 发送请求并等待对应id的响应
+func (c *Client) roundTrip(ctx context.Context, id string, data []byte) (*Response, error) {
+	call := &pendingCall{ch: make(chan *Response, 1)}
+	c.addPending(id, call)
+	defer c.removePending(id)
+
+	if err := c.safeWrite(data); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp, ok := <-call.ch:
+		if !ok {
+			return nil, ErrConnectionLost
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 移除等待中的请求
+func (c *Client) removePending(id string) {
+	c.pendingMu.Lock()
+	delete(c.pending, id)
+	c.pendingMu.Unlock()
+}
+
+// 连接断开时唤醒所有等待中的请求与确认发送
+func (c *Client) closePending() {
+	c.pendingMu.Lock()
+	for id, call := range c.pending {
+		close(call.ch)
+		delete(c.pending, id)
+	}
+	for id, ch := range c.receipts {
+		close(ch)
+		delete(c.receipts, id)
+	}
+	c.pendingMu.Unlock()
+}
+
+// 将响应分发给等待中的请求
+func (c *Client) dispatchResponse(body []byte) {
+	message, err := codec.DecodeReceiveMessagePB(body)
+	if err != nil {
+		log.Println("解析响应失败")
+		return
+	}
+	id, res, err := codec.ParseResponseReceiveMessage(message.Message)
+	if err != nil {
+		log.Println("解析响应体失败")
+		return
+	}
+
+	resp := &Response{
+		From:   message.From,
+		Status: codec.StatusCode(res.Status),
+		Body:   res.Body,
+	}
+
+	c.pendingMu.Lock()
+	call, ok := c.pending[id]
+	if ok && !call.multi {
+		delete(c.pending, id)
+	}
+	c.pendingMu.Unlock()
+	if !ok {
+		// 请求已超时或已取消，丢弃响应
+		return
+	}
+	if !call.multi {
+		call.ch <- resp
+		return
+	}
+	select {
+	case call.ch <- resp:
+	case <-call.done:
+	}
+}
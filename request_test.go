@@ -0,0 +1,91 @@
+package fernqclient_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 请求分发：已注册地址正常响应，未注册地址 404，处理函数 panic 时 500
+func TestRequestHandle(t *testing.T) {
+	s := newServer(t)
+	server := connect(t, s, "server")
+	server.Handle("/echo", func(ctx context.Context, req *fernqclient.Request) *fernqclient.Response {
+		return &fernqclient.Response{Body: append([]byte(req.From+":"), req.Body...)}
+	})
+	server.Handle("/panic", func(ctx context.Context, req *fernqclient.Request) *fernqclient.Response {
+		panic("处理失败")
+	})
+	client := connect(t, s, "client")
+
+	tests := []struct {
+		url    string
+		status codec.StatusCode
+		body   string
+	}{
+		{"/echo", codec.StatusOK, "client:ping"},
+		{"/missing", codec.StatusNotFound, ""},
+		{"/panic", codec.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			resp, err := client.Request(ctx, "server", tt.url, []byte("ping"))
+			if err != nil {
+				t.Fatalf("Request: %v", err)
+			}
+			if resp.Status != tt.status {
+				t.Fatalf("状态码 %d，期望 %d", resp.Status, tt.status)
+			}
+			if resp.From != "server" {
+				t.Fatalf("From = %q，期望 server", resp.From)
+			}
+			if tt.body != "" && string(resp.Body) != tt.body {
+				t.Fatalf("响应体 %q，期望 %q", resp.Body, tt.body)
+			}
+		})
+	}
+}
+
+// 目标不存在时由服务器回复 404，发送方为空
+func TestRequestNoTarget(t *testing.T) {
+	s := newServer(t)
+	client := connect(t, s, "client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	resp, err := client.Request(ctx, "nobody", "/echo", nil)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if resp.Status != codec.StatusNotFound || resp.From != "" {
+		t.Fatalf("收到 %d From=%q，期望 404 且 From 为空", resp.Status, resp.From)
+	}
+}
+
+// RequestAny 的响应方为实际处理请求的客户端
+func TestRequestAnyFrom(t *testing.T) {
+	s := newServer(t)
+	for _, name := range []string{"worker-1", "worker-2"} {
+		w := connect(t, s, name)
+		w.Handle("/name", func(ctx context.Context, req *fernqclient.Request) *fernqclient.Response {
+			return &fernqclient.Response{Body: []byte(name)}
+		})
+	}
+	client := connect(t, s, "client")
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		resp, err := client.RequestAny(ctx, "worker-[0-9]+", "/name", nil)
+		if err != nil {
+			t.Fatalf("RequestAny: %v", err)
+		}
+		if resp.Status != codec.StatusOK || resp.From != string(resp.Body) {
+			t.Fatalf("收到 %d From=%q Body=%q，From 应为处理请求的客户端", resp.Status, resp.From, resp.Body)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package fernqclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultSendQueueSize = 1024 // 默认的发送队列长度
+	maxWriteBatch        = 64   // 单次合并写入的最大帧数
+	controlQueueSize     = 8    // 心跳等控制帧的队列长度
+)
+
+// SendQueuePolicy 发送队列已满时的处理策略
+type SendQueuePolicy int
+
+const (
+	SendQueueBlock    SendQueuePolicy = iota // 阻塞等待队列空闲（默认）
+	SendQueueFailFast                        // 立即返回错误
+	SendQueueDrop                            // 丢弃本条消息并返回 nil
+)
+
+// WithSendQueue 设置发送队列长度及队列已满时的处理策略
+//
+// 参数:
+//   - size: 队列长度，<= 0 时使用 DefaultSendQueueSize
+//   - policy: 队列已满时的处理策略
+func WithSendQueue(size int, policy SendQueuePolicy) Option {
+	return func(o *options) {
+		o.sendQueueSize = size
+		o.sendQueuePolicy = policy
+	}
+}
+
+// WithWriteTimeout 设置单次写入连接的超时时间，超时视为连接失效并断开。默认不限制
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.writeTimeout = d
+	}
+}
+
+// 发送队列中的一项，data 为 nil 时为 Flush 屏障
+type sendItem struct {
+	data    []byte
+	buf     *[]byte    // data 所属的缓冲池缓冲，写入后归还
+	barrier chan error // Flush 屏障，之前的帧写入完成后通知
+}
+
+// 写入完成后释放队列项
+func (it *sendItem) release() {
+	if it.buf != nil {
+		putFrameBuffer(it.buf)
+		it.buf = nil
+	}
+}
+
+// 发送队列，在一次 Connect 到 Stop 之间保持不变，未写出的帧在重连后继续发送
+type sendQueue struct {
+	items   chan *sendItem
+	control chan []byte
+	policy  SendQueuePolicy
+	done    chan struct{}  // 客户端停止时关闭
+	dropped *atomic.Uint64 // 丢弃计数
+}
+
+// 创建发送队列
+func newSendQueue(size int, policy SendQueuePolicy, dropped *atomic.Uint64) *sendQueue {
+	if size <= 0 {
+		size = DefaultSendQueueSize
+	}
+	return &sendQueue{
+		items:   make(chan *sendItem, size),
+		control: make(chan []byte, controlQueueSize),
+		policy:  policy,
+		done:    make(chan struct{}),
+		dropped: dropped,
+	}
+}
+
+// 按队列策略加入一项
+func (q *sendQueue) push(it *sendItem) error {
+	select {
+	case q.items <- it:
+		return nil
+	case <-q.done:
+		it.release()
+		return ErrClosed
+	default:
+	}
+	switch q.policy {
+	case SendQueueFailFast:
+		it.release()
+		return ErrSendQueueFull
+	case SendQueueDrop:
+		it.release()
+		q.dropped.Add(1)
+		return nil
+	}
+	select {
+	case q.items <- it:
+		return nil
+	case <-q.done:
+		it.release()
+		return ErrClosed
+	}
+}
+
+// 关闭队列并释放未写出的帧，等待中的 Flush 返回 ErrClosed
+func (q *sendQueue) close() {
+	close(q.done)
+	for {
+		select {
+		case it := <-q.items:
+			it.release()
+		default:
+			return
+		}
+	}
+}
+
+// 获取当前的发送队列，未连接时返回错误
+func (c *Client) currentQueue() (*sendQueue, error) {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	if !c.running {
+		return nil, ErrNotConnected
+	}
+	return c.queue, nil
+}
+
+// 将消息帧加入发送队列
+//
+// 重连期间消息保留在队列中，重连成功后按顺序发送。
+func (c *Client) enqueue(data []byte, buf *[]byte) error {
+	q, err := c.currentQueue()
+	if err != nil {
+		if buf != nil {
+			putFrameBuffer(buf)
+		}
+		return err
+	}
+	return q.push(&sendItem{data: data, buf: buf})
+}
+
+// 发送心跳等控制帧，优先于普通消息写出，队列已满时丢弃
+func (c *Client) sendControl(frame []byte) error {
+	q, err := c.currentQueue()
+	if err != nil {
+		return err
+	}
+	select {
+	case q.control <- frame:
+		return nil
+	default:
+		return fmt.Errorf("控制帧队列已满")
+	}
+}
+
+// Flush 等待调用前加入队列的消息全部写入连接
+//
+// 参数:
+//   - ctx: 控制等待时间
+//
+// 返回值:
+//   - error: 写入失败、客户端停止或 ctx 结束时返回错误
+//
+// 注意事项:
+//   - 写入连接仅表示数据已交给操作系统，不代表对方已收到
+//   - 重连期间调用会等待重连成功并写出后返回
+func (c *Client) Flush(ctx context.Context) error {
+	q, err := c.currentQueue()
+	if err != nil {
+		return err
+	}
+	barrier := make(chan error, 1)
+	select {
+	case q.items <- &sendItem{barrier: barrier}:
+	case <-q.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-barrier:
+		return err
+	case <-q.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 写协程，将发送队列中的帧合并后写入连接，写入失败时关闭连接
+// 返回的通道在写协程退出后关闭
+func (c *Client) writer(conn net.Conn, closer *connCloser, done <-chan struct{}) <-chan struct{} {
+	q := c.queue
+	stopped := make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(stopped)
+		batch := make([]*sendItem, 0, maxWriteBatch)
+		bufs := make(net.Buffers, 0, maxWriteBatch+controlQueueSize)
+		for {
+			// 等待第一帧
+			var first *sendItem
+			var control []byte
+			select {
+			case <-done:
+				return
+			case control = <-q.control:
+			case first = <-q.items:
+			}
+
+			// 控制帧优先写出，再合并已在队列中的消息
+			bufs = bufs[:0]
+			if control != nil {
+				bufs = append(bufs, control)
+			}
+			bufs = drainControl(q, bufs)
+			batch = batch[:0]
+			if first != nil {
+				batch = append(batch, first)
+			}
+		collect:
+			for len(batch) < maxWriteBatch {
+				select {
+				case it := <-q.items:
+					batch = append(batch, it)
+				default:
+					break collect
+				}
+			}
+			for _, it := range batch {
+				if it.data != nil {
+					bufs = append(bufs, it.data)
+				}
+			}
+
+			err := c.writeBuffers(conn, bufs)
+			for _, it := range batch {
+				it.release()
+				if it.barrier != nil {
+					it.barrier <- err
+				}
+			}
+			if err != nil {
+				log.Printf("写入数据失败: %v", err)
+				closer.close(c.closeReason(fmt.Errorf("写入数据失败: %w", err)))
+				return
+			}
+		}
+	}()
+	return stopped
+}
+
+// 取出所有等待中的控制帧
+func drainControl(q *sendQueue, bufs net.Buffers) net.Buffers {
+	for {
+		select {
+		case control := <-q.control:
+			bufs = append(bufs, control)
+		default:
+			return bufs
+		}
+	}
+}
+
+// 合并写入多个帧，支持时使用 writev
+func (c *Client) writeBuffers(conn net.Conn, bufs net.Buffers) error {
+	if len(bufs) == 0 {
+		return nil
+	}
+	if d := c.opts.writeTimeout; d > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(d)); err != nil {
+			return err
+		}
+	}
+	_, err := bufs.WriteTo(conn)
+	return err
+}
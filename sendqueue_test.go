@@ -0,0 +1,32 @@
+package fernqclient_test
+
+import (
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 在 Connected 状态回调中发送消息
+func TestSendFromConnectedCallback(t *testing.T) {
+	s := newServer(t)
+	recv := connect(t, s, "recv")
+
+	sender := fernqclient.NewClient("sender")
+	errc := make(chan error, 1)
+	sender.OnStateChange(func(ev fernqclient.StateEvent) {
+		if ev.To == fernqclient.StateConnected {
+			errc <- sender.Send("recv", []byte("hello"))
+		}
+	})
+	if err := sender.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Stop()
+
+	if err := <-errc; err != nil {
+		t.Fatalf("回调中发送失败: %v", err)
+	}
+	if msg := receive(t, recv); string(msg.Message) != "hello" || msg.From != "sender" {
+		t.Fatalf("收到 %s: %q，期望 sender: \"hello\"", msg.From, msg.Message)
+	}
+}
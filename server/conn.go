@@ -0,0 +1,207 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 写协程单次合并写入的最大帧数
+const maxWriteBatch = 64
+
+// 心跳帧内容固定，只编码一次
+var (
+	pingFrame = codec.AppendPing(nil)
+	pongFrame = codec.AppendPong(nil)
+)
+
+// 客户端连接
+type conn struct {
+	net.Conn
+	srv *Server
+
+	name string // 客户端名称，验证通过后设置
+	room *room  // 所在房间，验证通过后设置
+
+	out       chan []byte   // 发送队列，由写协程写入连接
+	done      chan struct{} // 连接关闭时关闭
+	closeOnce sync.Once
+}
+
+// 创建连接
+func newConn(s *Server, nc net.Conn) *conn {
+	return &conn{
+		Conn: nc,
+		srv:  s,
+		out:  make(chan []byte, s.cfg.SendQueueSize),
+		done: make(chan struct{}),
+	}
+}
+
+// Close 关闭连接，可重复调用
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// 将帧加入发送队列，队列已满时视为消费过慢并断开
+func (c *conn) send(frame []byte) {
+	select {
+	case c.out <- frame:
+	case <-c.done:
+	default:
+		c.srv.logf("客户端 %s 发送队列已满，断开连接", c.name)
+		c.Close()
+	}
+}
+
+// 处理连接，返回时连接已关闭
+func (c *conn) serve() {
+	defer c.srv.leave(c)
+	defer c.Close()
+
+	go c.writer()
+
+	dec := codec.NewDecoder(c, c.srv.cfg.MaxFrameSize)
+	defer dec.Release()
+
+	if !c.handshake(dec) {
+		return
+	}
+
+	if c.srv.cfg.PingInterval > 0 {
+		go c.pinger()
+	}
+
+	for {
+		if d := c.srv.readTimeout(); d > 0 {
+			if err := c.SetReadDeadline(time.Now().Add(d)); err != nil {
+				return
+			}
+		}
+		msgType, body, err := dec.Next()
+		if err != nil {
+			var ne net.Error
+			switch {
+			case errors.As(err, &ne) && ne.Timeout():
+				c.srv.logf("客户端 %s 心跳超时，断开连接", c.name)
+			case errors.Is(err, codec.ErrFrameTooLarge), errors.Is(err, codec.ErrMalformedHeader):
+				c.srv.logf("客户端 %s 发送了无效的帧: %v", c.name, err)
+			}
+			return
+		}
+		if err := c.srv.route(c, msgType, body); err != nil {
+			c.srv.logf("处理 %s 的消息失败: %v", c.name, err)
+		}
+	}
+}
+
+// 等待验证消息并加入房间，失败时返回 false
+func (c *conn) handshake(dec *codec.Decoder) bool {
+	if err := c.SetReadDeadline(time.Now().Add(c.srv.cfg.HandshakeTimeout)); err != nil {
+		return false
+	}
+	for {
+		msgType, body, err := dec.Next()
+		if err != nil {
+			return false
+		}
+		switch msgType {
+		case codec.TypePing:
+			c.send(pongFrame)
+			continue
+		case codec.TypePong:
+			continue
+		case codec.TypeRoomVerify:
+		default:
+			return false
+		}
+
+		info, err := codec.ValidateAndExtractInfo(body)
+		if err != nil {
+			c.verifyResult("", false, err.Error())
+			return false
+		}
+		if err := c.srv.join(c, info); err != nil {
+			c.verifyResult(info.RoomName, false, err.Error())
+			return false
+		}
+		return c.SetReadDeadline(time.Time{}) == nil
+	}
+}
+
+// 发送验证结果，失败时等待结果写出后再断开
+func (c *conn) verifyResult(roomName string, res bool, msg string) {
+	frame, err := codec.CreateRoomVerifyRes(roomName, res, msg)
+	if err != nil {
+		return
+	}
+	if res {
+		c.send(frame)
+		return
+	}
+	c.SetWriteDeadline(time.Now().Add(c.srv.cfg.WriteTimeout))
+	c.Write(frame)
+}
+
+// 写协程，合并发送队列中的帧写入连接
+func (c *conn) writer() {
+	bufs := make(net.Buffers, 0, maxWriteBatch)
+	for {
+		var frame []byte
+		select {
+		case <-c.done:
+			return
+		case frame = <-c.out:
+		}
+		bufs = append(bufs[:0], frame)
+	collect:
+		for len(bufs) < maxWriteBatch {
+			select {
+			case frame = <-c.out:
+				bufs = append(bufs, frame)
+			default:
+				break collect
+			}
+		}
+		if err := c.SetWriteDeadline(time.Now().Add(c.srv.cfg.WriteTimeout)); err != nil {
+			c.Close()
+			return
+		}
+		// WriteTo 会消耗切片，使用副本以便复用 bufs
+		pending := bufs
+		if _, err := pending.WriteTo(c.Conn); err != nil {
+			c.Close()
+			return
+		}
+	}
+}
+
+// 心跳协程，定时发送 Ping
+func (c *conn) pinger() {
+	ticker := time.NewTicker(c.srv.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.send(pingFrame)
+		}
+	}
+}
+
+// 验证通过后的读取超时，0 表示不限制
+func (s *Server) readTimeout() time.Duration {
+	if s.cfg.PingInterval <= 0 {
+		return 0
+	}
+	return s.cfg.PingInterval + s.cfg.PongTimeout
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 按消息类型转发
+//
+// 路由规则:
+//   - 房间广播：发送给房间内所有客户端，包括发送方
+//   - P2P中转、请求、响应：发送给名称完全相同的客户端
+//   - 扫描组播、扫描全部请求：发送给名称与正则完全匹配的所有客户端
+//   - 扫描单播、扫描请求：发送给名称与正则完全匹配的客户端中的随机一个
+//
+// 状态回复:
+//   - 请求找不到接收方时回复 StatusNotFound，消息超过 MaxMessageSize 时回复 StatusPayloadTooLarge
+//   - 确认发送（TypeConfirmSend）的结果通过投递回执返回，同样使用 StatusNotFound 与 StatusPayloadTooLarge
+//   - 广播、P2P中转、扫描组播、扫描单播没有可供回复的消息id，找不到接收方或超过 MaxMessageSize 时直接丢弃，
+//     不通知发送方；发送方需要知道投递结果时应使用确认发送（SendConfirmed、ScanSendConfirmed）
+func (s *Server) route(c *conn, msgType codec.FernqTypeCode, body []byte) error {
+	switch msgType {
+	case codec.TypePing:
+		c.send(pongFrame)
+		return nil
+	case codec.TypePong:
+		return nil
+	case codec.TypeConfirmSend:
+		return s.confirm(c, body)
+	}
+
+	tm, err := codec.DecodeTransitMessagePB(body)
+	if err != nil {
+		return err
+	}
+	isRequest := msgType == codec.TypeRequestMessage || msgType == codec.TypeRequestMessageScan || msgType == codec.TypeRequestMessageAll
+
+	if limit := s.cfg.MaxMessageSize; limit > 0 && len(tm.Message) > limit {
+		if isRequest {
+			return s.replyStatus(c, tm, codec.StatusPayloadTooLarge)
+		}
+		return fmt.Errorf("消息长度 %d 超过上限 %d", len(tm.Message), limit)
+	}
+
+	var frame []byte
+	switch msgType {
+	case codec.TypeRoomBroadcast, codec.TypeP2PRelay, codec.TypeUserScan, codec.TypeUserScanSingle:
+		frame, err = codec.CreateReceiveMessageKind(c.name, codec.DeliveryKindOf(msgType), tm.Target, tm.Message)
+	case codec.TypeRequestMessage, codec.TypeRequestMessageScan, codec.TypeRequestMessageAll:
+		// 接收方统一收到 TypeRequestMessage，响应按请求id返回给请求方
+		frame, err = codec.CreateRequestReceiveMessage(c.name, tm.Message)
+	case codec.TypeResponseMessage:
+		frame, err = codec.CreateResponseReceiveMessage(c.name, tm.Message)
+	default:
+		return fmt.Errorf("未知的消息类型 0x%X", uint16(msgType))
+	}
+	if err != nil {
+		return err
+	}
+
+	targets, err := s.targets(c, msgType, tm.Target)
+	if err != nil {
+		if isRequest {
+			return s.replyStatus(c, tm, codec.StatusBadRequest)
+		}
+		return err
+	}
+	if len(targets) == 0 && (msgType == codec.TypeRequestMessage || msgType == codec.TypeRequestMessageScan) {
+		return s.replyStatus(c, tm, codec.StatusNotFound)
+	}
+	for _, t := range targets {
+		t.send(frame)
+	}
+	return nil
+}
+
+// 投递方式对应的发送帧类型
+var sendTypes = map[codec.DeliveryKind]codec.FernqTypeCode{
+	codec.DeliveryDirect:     codec.TypeP2PRelay,
+	codec.DeliveryBroadcast:  codec.TypeRoomBroadcast,
+	codec.DeliveryScan:       codec.TypeUserScan,
+	codec.DeliveryScanSingle: codec.TypeUserScanSingle,
+}
+
+// 处理确认发送，按投递方式转发后返回投递回执
+func (s *Server) confirm(c *conn, body []byte) error {
+	cm, err := codec.DecodeConfirmMessagePB(body)
+	if err != nil {
+		return err
+	}
+	kind := codec.DeliveryKind(cm.Kind)
+
+	status, recipients := codec.StatusOK, 0
+	msgType, ok := sendTypes[kind]
+	switch {
+	case !ok:
+		status = codec.StatusBadRequest
+	case s.cfg.MaxMessageSize > 0 && len(cm.Message) > s.cfg.MaxMessageSize:
+		status = codec.StatusPayloadTooLarge
+	default:
+		targets, err := s.targets(c, msgType, cm.Target)
+		if err != nil {
+			status = codec.StatusBadRequest
+			break
+		}
+		if len(targets) == 0 {
+			status = codec.StatusNotFound
+			break
+		}
+		frame, err := codec.CreateReceiveMessageKind(c.name, kind, cm.Target, cm.Message)
+		if err != nil {
+			return err
+		}
+		for _, t := range targets {
+			t.send(frame)
+		}
+		recipients = len(targets)
+	}
+
+	receipt, err := codec.CreateDeliveryReceipt(cm.Id, status, recipients)
+	if err != nil {
+		return err
+	}
+	c.send(receipt)
+	return nil
+}
+
+// 代替接收方向请求方回复状态码，响应的发送方为空，表示由服务器生成
+func (s *Server) replyStatus(c *conn, tm *codec.TransitMessage, status codec.StatusCode) error {
+	id, err := codec.ParseRequestOrResponseId(tm.Message)
+	if err != nil {
+		return err
+	}
+	body, err := codec.EncodeResponseBodyPB(&codec.ResponseBody{Status: int32(status)})
+	if err != nil {
+		return err
+	}
+	frame, err := codec.CreateResponseReceiveMessage("", append(id, body...))
+	if err != nil {
+		return err
+	}
+	c.send(frame)
+	return nil
+}
+
+// 计算消息的接收方
+func (s *Server) targets(c *conn, msgType codec.FernqTypeCode, target string) ([]*conn, error) {
+	var re *regexp.Regexp
+	switch msgType {
+	case codec.TypeUserScan, codec.TypeUserScanSingle, codec.TypeRequestMessageScan, codec.TypeRequestMessageAll:
+		var err error
+		re, err = regexp.Compile("^(?:" + target + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 '%s': %w", target, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	members := c.room.members
+
+	if msgType == codec.TypeRoomBroadcast {
+		all := make([]*conn, 0, len(members))
+		for _, m := range members {
+			all = append(all, m)
+		}
+		return all, nil
+	}
+	if re == nil {
+		if m, ok := members[target]; ok {
+			return []*conn{m}, nil
+		}
+		return nil, nil
+	}
+
+	var matched []*conn
+	for name, m := range members {
+		if re.MatchString(name) {
+			matched = append(matched, m)
+		}
+	}
+	if len(matched) > 1 && (msgType == codec.TypeUserScanSingle || msgType == codec.TypeRequestMessageScan) {
+		return []*conn{matched[rand.IntN(len(matched))]}, nil
+	}
+	return matched, nil
+}
@@ -0,0 +1,258 @@
+// Package server 实现 FernQ 服务端，与 fernqclient 使用相同的 codec 线格式
+//
+// cmd/fernqd 与 fernqtest 均基于该包构建。
+//
+// 使用方式:
+//
+//	srv := server.New(server.Config{PingInterval: 30 * time.Second})
+//	srv.AddRoom("uuid", "room", "password")
+//	ln, _ := net.Listen("tcp", ":9147")
+//	log.Fatal(srv.Serve(ln))
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 默认配置
+const (
+	DefaultHandshakeTimeout = 30 * time.Second
+	DefaultWriteTimeout     = 10 * time.Second
+	DefaultSendQueueSize    = 1024
+)
+
+// ErrServerClosed 调用 Close 后 Serve 返回的错误
+var ErrServerClosed = errors.New("server: 服务器已关闭")
+
+// Config 服务器配置，零值可用
+type Config struct {
+	// OpenRooms 为 true 时，未通过 AddRoom 创建的房间在第一个客户端加入时自动创建，
+	// 密码取该客户端提供的 room_pass；为 false 时拒绝加入未创建的房间
+	OpenRooms bool
+
+	MaxFrameSize     int           // 最大帧长度（包括帧头），超过时断开连接，0 表示使用 codec.DefaultMaxFrameSize
+	MaxMessageSize   int           // 最大消息长度，超过时请求与确认发送回复 StatusPayloadTooLarge，其他消息丢弃，0 表示不限制
+	HandshakeTimeout time.Duration // 连接后等待验证消息的超时，0 表示使用 DefaultHandshakeTimeout
+	WriteTimeout     time.Duration // 单次写入超时，0 表示使用 DefaultWriteTimeout
+	SendQueueSize    int           // 每个客户端的发送队列长度，队列满时视为消费过慢并断开，0 表示使用 DefaultSendQueueSize
+
+	// PingInterval 大于 0 时定时向客户端发送 Ping，
+	// 超过 PingInterval + PongTimeout 未收到客户端任何数据时断开连接
+	PingInterval time.Duration
+	PongTimeout  time.Duration // 0 时与 PingInterval 相同
+
+	// Logger 日志输出，nil 时使用 log 包的默认输出
+	Logger *log.Logger
+}
+
+// Server FernQ 服务器
+type Server struct {
+	cfg Config
+
+	mu        sync.Mutex
+	rooms     map[string]*room          // 键为 UUID#房间名
+	conns     map[*conn]struct{}        // 所有连接，包括未通过验证的
+	listeners map[net.Listener]struct{} // 正在服务的监听器
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// 房间
+type room struct {
+	name     string
+	password string
+	members  map[string]*conn // 键为客户端名称
+}
+
+// New 创建服务器
+func New(cfg Config) *Server {
+	if cfg.MaxFrameSize <= 0 {
+		cfg.MaxFrameSize = codec.DefaultMaxFrameSize
+	}
+	if cfg.HandshakeTimeout <= 0 {
+		cfg.HandshakeTimeout = DefaultHandshakeTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.SendQueueSize <= 0 {
+		cfg.SendQueueSize = DefaultSendQueueSize
+	}
+	if cfg.PongTimeout <= 0 {
+		cfg.PongTimeout = cfg.PingInterval
+	}
+	return &Server{
+		cfg:       cfg,
+		rooms:     make(map[string]*room),
+		conns:     make(map[*conn]struct{}),
+		listeners: make(map[net.Listener]struct{}),
+	}
+}
+
+// 房间的键
+func roomKey(uuid, roomName string) string {
+	return uuid + "#" + roomName
+}
+
+// AddRoom 创建房间并设置密码，已存在时只修改密码
+func (s *Server) AddRoom(uuid, roomName, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := roomKey(uuid, roomName)
+	if r, ok := s.rooms[key]; ok {
+		r.password = password
+		return
+	}
+	s.rooms[key] = &room{name: roomName, password: password, members: make(map[string]*conn)}
+}
+
+// Clients 返回房间内已通过验证的客户端名称，按名称排序
+func (s *Server) Clients(uuid, roomName string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rooms[roomKey(uuid, roomName)]
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(r.members))
+	for name := range r.members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Serve 在监听器上接受连接，直到监听器出错或调用 Close
+//
+// 返回值:
+//   - error: 调用 Close 后返回 ErrServerClosed，否则返回 Accept 的错误
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	s.listeners[ln] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.listeners, ln)
+		s.mu.Unlock()
+	}()
+
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		s.ServeConn(nc)
+	}
+}
+
+// ServeConn 在已建立的连接上运行 FernQ 协议，立即返回
+//
+// 可用于 TLS、WebSocket 等由调用方完成握手的连接。
+func (s *Server) ServeConn(nc net.Conn) {
+	c := newConn(s, nc)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		nc.Close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		c.serve()
+	}()
+}
+
+// CloseClientConnections 断开所有客户端连接，服务器继续接受新连接
+func (s *Server) CloseClientConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+// Close 关闭所有监听器和连接，等待所有连接协程退出
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		ln.Close()
+	}
+	s.mu.Unlock()
+	s.CloseClientConnections()
+	s.wg.Wait()
+	return nil
+}
+
+// 记录日志
+func (s *Server) logf(format string, args ...any) {
+	if s.cfg.Logger != nil {
+		s.cfg.Logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// 房间验证，通过时设置连接的名称与房间、发出验证结果并加入房间
+func (s *Server) join(c *conn, info codec.RoomInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := roomKey(info.UUID, info.RoomName)
+	r, ok := s.rooms[key]
+	if !ok {
+		if !s.cfg.OpenRooms {
+			return fmt.Errorf("房间不存在")
+		}
+		r = &room{name: info.RoomName, password: info.Password, members: make(map[string]*conn)}
+		s.rooms[key] = r
+	}
+	if r.password != info.Password {
+		return fmt.Errorf("房间密码错误")
+	}
+	if r.members[info.Username] != nil {
+		return fmt.Errorf("客户端名称已存在")
+	}
+	// 加入房间前设置名称并发出验证结果，其他连接转发的消息只会排在验证结果之后
+	c.name, c.room = info.Username, r
+	c.verifyResult(info.RoomName, true, "")
+	r.members[info.Username] = c
+	return nil
+}
+
+// 连接断开时移除
+func (s *Server) leave(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+	if c.room != nil && c.room.members[c.name] == c {
+		delete(c.room.members, c.name)
+	}
+}
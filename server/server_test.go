@@ -0,0 +1,447 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 测试中等待帧的超时时间
+const testTimeout = 5 * time.Second
+
+// 创建服务器，不输出日志，测试结束时关闭
+func newServer(t *testing.T, cfg Config) *Server {
+	t.Helper()
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(io.Discard, "", 0)
+	}
+	s := New(cfg)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// 测试客户端，直接收发 codec 编码的帧
+type testClient struct {
+	t *testing.T
+	net.Conn
+	dec *codec.Decoder
+}
+
+// 通过 net.Pipe 连接服务器
+func dial(t *testing.T, s *Server) *testClient {
+	t.Helper()
+	client, conn := net.Pipe()
+	s.ServeConn(conn)
+	t.Cleanup(func() { client.Close() })
+	return &testClient{t: t, Conn: client, dec: codec.NewDecoder(client, 0)}
+}
+
+// 连接服务器并加入 uuid#room，验证失败时终止测试
+func join(t *testing.T, s *Server, name string) *testClient {
+	t.Helper()
+	c := dial(t, s)
+	if ok, msg := c.verify(name, "room", "pass"); !ok {
+		t.Fatalf("%s 验证失败: %s", name, msg)
+	}
+	return c
+}
+
+// 写入一帧
+func (c *testClient) write(frame []byte) {
+	c.t.Helper()
+	c.SetWriteDeadline(time.Now().Add(testTimeout))
+	if _, err := c.Write(frame); err != nil {
+		c.t.Fatalf("写入失败: %v", err)
+	}
+}
+
+// 读取下一帧
+func (c *testClient) next() (codec.FernqTypeCode, []byte, error) {
+	c.SetReadDeadline(time.Now().Add(testTimeout))
+	return c.dec.Next()
+}
+
+// 读取下一个非心跳帧
+func (c *testClient) expect(want codec.FernqTypeCode) []byte {
+	c.t.Helper()
+	for {
+		msgType, body, err := c.next()
+		if err != nil {
+			c.t.Fatalf("读取失败: %v", err)
+		}
+		if msgType == codec.TypePing || msgType == codec.TypePong {
+			continue
+		}
+		if msgType != want {
+			c.t.Fatalf("收到类型 0x%X，期望 0x%X", uint16(msgType), uint16(want))
+		}
+		return body
+	}
+}
+
+// 发送房间验证并返回验证结果
+func (c *testClient) verify(name, roomName, password string) (bool, string) {
+	c.t.Helper()
+	_, raw, err := codec.ValidateAndExtractAddress(name, "fernq://connect/127.0.0.1/uuid#"+roomName+"?room_pass="+password)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.write(raw)
+	ok, msg, err := codec.ParseRoomVerifyRes(c.expect(codec.TypeRoomVerifyRes))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return ok, msg
+}
+
+// 读取下一条接收消息
+func (c *testClient) receive() *codec.ReceiveMessage {
+	c.t.Helper()
+	rm, err := codec.DecodeReceiveMessagePB(c.expect(codec.TypeReceiveMessage))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return rm
+}
+
+// 读取下一个响应，返回响应方与状态码
+func (c *testClient) response() (string, codec.StatusCode) {
+	c.t.Helper()
+	rm, err := codec.DecodeReceiveMessagePB(c.expect(codec.TypeResponseMessage))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	_, body, err := codec.ParseResponseReceiveMessage(rm.Message)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return rm.From, codec.StatusCode(body.Status)
+}
+
+// 等待服务器断开连接
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	for {
+		_, _, err := c.next()
+		if err == nil {
+			continue
+		}
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			c.t.Fatal("等待断开超时")
+		}
+		return
+	}
+}
+
+// 监听本地端口时验证通过，后续消息正常转发
+func TestServe(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	c := &testClient{t: t, Conn: nc, dec: codec.NewDecoder(nc, 0)}
+	if ok, msg := c.verify("alice", "room", "pass"); !ok {
+		t.Fatalf("验证失败: %s", msg)
+	}
+	if got := s.Clients("uuid", "room"); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("房间成员 %v，期望 [alice]", got)
+	}
+	c.write(codec.AppendP2PRelay(nil, "alice", []byte("hi")))
+	if rm := c.receive(); rm.From != "alice" || string(rm.Message) != "hi" {
+		t.Fatalf("收到 %s: %q", rm.From, rm.Message)
+	}
+}
+
+// 房间验证：已配置的房间校验密码，未配置的房间由 OpenRooms 决定
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     bool
+		room     string
+		password string
+		ok       bool
+		msg      string
+	}{
+		{"accept", false, "room", "secret", true, ""},
+		{"wrong password", false, "room", "wrong", false, "房间密码错误"},
+		{"unknown room", false, "other", "secret", false, "房间不存在"},
+		{"open room", true, "other", "secret", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newServer(t, Config{OpenRooms: tt.open})
+			s.AddRoom("uuid", "room", "secret")
+
+			c := dial(t, s)
+			ok, msg := c.verify("alice", tt.room, tt.password)
+			if ok != tt.ok || msg != tt.msg {
+				t.Fatalf("验证结果 %v %q，期望 %v %q", ok, msg, tt.ok, tt.msg)
+			}
+			if !ok {
+				c.expectClosed()
+			}
+		})
+	}
+}
+
+// 开放房间的密码取第一个加入的客户端，之后密码不一致或名称重复时拒绝
+func TestHandshakeOpenRoom(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	join(t, s, "alice")
+
+	c := dial(t, s)
+	if ok, msg := c.verify("bob", "room", "wrong"); ok || msg != "房间密码错误" {
+		t.Fatalf("验证结果 %v %q，期望密码错误", ok, msg)
+	}
+	c = dial(t, s)
+	if ok, msg := c.verify("alice", "room", "pass"); ok || msg != "客户端名称已存在" {
+		t.Fatalf("验证结果 %v %q，期望名称已存在", ok, msg)
+	}
+	if got := s.Clients("uuid", "room"); !slices.Equal(got, []string{"alice"}) {
+		t.Fatalf("房间成员 %v，期望 [alice]", got)
+	}
+}
+
+// 验证消息无效或第一帧不是验证消息时断开
+func TestHandshakeInvalid(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+
+	c := dial(t, s)
+	frame, _ := codec.Encode(codec.TypeRoomVerify, []byte("not protobuf"))
+	c.write(frame)
+	if ok, _, _ := codec.ParseRoomVerifyRes(c.expect(codec.TypeRoomVerifyRes)); ok {
+		t.Fatal("无效的验证消息通过了验证")
+	}
+	c.expectClosed()
+
+	c = dial(t, s)
+	c.write(codec.AppendP2PRelay(nil, "alice", []byte("hi")))
+	c.expectClosed()
+}
+
+// 超过握手超时未发送验证消息时断开
+func TestHandshakeTimeout(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true, HandshakeTimeout: 50 * time.Millisecond})
+	c := dial(t, s)
+	c.expectClosed()
+}
+
+// 广播发送给房间内所有客户端，包括发送方
+func TestBroadcast(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	alice, bob := join(t, s, "alice"), join(t, s, "bob")
+
+	alice.write(codec.AppendRoomBroadcast(nil, "room", []byte("hello")))
+	for _, c := range []*testClient{alice, bob} {
+		rm := c.receive()
+		if rm.From != "alice" || string(rm.Message) != "hello" || codec.DeliveryKind(rm.Kind) != codec.DeliveryBroadcast {
+			t.Fatalf("收到 %s %v: %q，期望 alice 的广播", rm.From, codec.DeliveryKind(rm.Kind), rm.Message)
+		}
+	}
+}
+
+// 扫描使用完全匹配，正则只匹配名称的一部分时不投递
+func TestScanAnchored(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	sender := join(t, s, "sender")
+	members := map[string]*testClient{}
+	for _, name := range []string{"worker", "worker-1", "coworker"} {
+		members[name] = join(t, s, name)
+	}
+
+	sender.write(codec.AppendUserScan(nil, "worker", []byte("scan")))
+	// 随后向每个客户端单独发送标记消息，未匹配的客户端第一条收到的应是标记
+	for name := range members {
+		sender.write(codec.AppendP2PRelay(nil, name, []byte("mark")))
+	}
+	for name, c := range members {
+		want := "mark"
+		if name == "worker" {
+			want = "scan"
+		}
+		if rm := c.receive(); string(rm.Message) != want {
+			t.Fatalf("%s 收到 %q，期望 %q", name, rm.Message, want)
+		}
+	}
+
+	// 扫描单播只投递给匹配客户端中的一个
+	matched := []string{"worker-1", "coworker"}
+	sender.write(codec.AppendUserScanSingle(nil, "worker-[0-9]+|cowork.*", []byte("single")))
+	for _, name := range matched {
+		sender.write(codec.AppendP2PRelay(nil, name, []byte("mark")))
+	}
+	got := 0
+	for _, name := range matched {
+		if rm := members[name].receive(); string(rm.Message) == "single" {
+			got++
+			members[name].receive()
+		}
+	}
+	if got != 1 {
+		t.Fatalf("扫描单播投递给 %d 个客户端，期望 1 个", got)
+	}
+}
+
+// 请求与确认发送找不到接收方时回复 404，超过 MaxMessageSize 时回复 413
+func TestStatusReplies(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true, MaxMessageSize: 64})
+	alice := join(t, s, "alice")
+	join(t, s, "bob")
+	large := make([]byte, 128)
+
+	tests := []struct {
+		name   string
+		target string
+		body   []byte
+		status codec.StatusCode
+	}{
+		{"not found", "nobody", nil, codec.StatusNotFound},
+		{"too large", "bob", large, codec.StatusPayloadTooLarge},
+	}
+	for _, tt := range tests {
+		_, frame, err := codec.CreateRequestMessage(tt.target, "/echo", tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alice.write(frame)
+		if from, status := alice.response(); from != "" || status != tt.status {
+			t.Fatalf("%s: 请求收到 %q %d，期望服务器回复 %d", tt.name, from, status, tt.status)
+		}
+
+		_, frame, err = codec.CreateConfirmSend(codec.DeliveryDirect, tt.target, tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		alice.write(frame)
+		_, receipt, err := codec.ParseDeliveryReceipt(alice.expect(codec.TypeDeliveryReceipt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codec.StatusCode(receipt.Status) != tt.status || receipt.Recipients != 0 {
+			t.Fatalf("%s: 回执 %d/%d，期望 %d/0", tt.name, receipt.Status, receipt.Recipients, tt.status)
+		}
+	}
+}
+
+// 普通消息找不到接收方时丢弃，不回复发送方
+func TestDropUnknownTarget(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	alice := join(t, s, "alice")
+
+	alice.write(codec.AppendP2PRelay(nil, "nobody", []byte("lost")))
+	alice.write(codec.AppendUserScan(nil, "nobody-.*", []byte("lost")))
+	// 第一个收到的帧应是随后请求的 404，而不是针对普通消息的回复
+	_, frame, _ := codec.CreateRequestMessage("nobody", "/echo", nil)
+	alice.write(frame)
+	if _, status := alice.response(); status != codec.StatusNotFound {
+		t.Fatalf("状态码 %d，期望 404", status)
+	}
+}
+
+// 发送队列满时断开消费过慢的客户端，不影响其他客户端
+func TestSlowConsumer(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true, SendQueueSize: 4})
+	fast := join(t, s, "fast")
+	slow := join(t, s, "slow")
+
+	// slow 不读取，net.Pipe 没有缓冲，写协程阻塞后发送队列很快被填满
+	for i := 0; i < 16; i++ {
+		fast.write(codec.AppendP2PRelay(nil, "slow", []byte("flood")))
+	}
+	deadline := time.Now().Add(testTimeout)
+	for slices.Contains(s.Clients("uuid", "room"), "slow") {
+		if time.Now().After(deadline) {
+			t.Fatal("消费过慢的客户端未被断开")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	slow.expectClosed()
+
+	fast.write(codec.AppendP2PRelay(nil, "fast", []byte("still here")))
+	if rm := fast.receive(); string(rm.Message) != "still here" {
+		t.Fatalf("收到 %q", rm.Message)
+	}
+}
+
+// 开启心跳时定时发送 Ping，客户端回复时保持连接，超时未收到任何数据时断开
+func TestPingTimeout(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true, PingInterval: 30 * time.Millisecond, PongTimeout: 30 * time.Millisecond})
+	alive, silent := join(t, s, "alive"), join(t, s, "silent")
+
+	// alive 回复每个 Ping，silent 只读取不回复
+	stop := time.After(300 * time.Millisecond)
+	pings := 0
+	go func() {
+		for {
+			if _, _, err := silent.next(); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-stop:
+			if pings < 3 {
+				t.Fatalf("收到 %d 个 Ping，期望至少 3 个", pings)
+			}
+			if got := s.Clients("uuid", "room"); !slices.Equal(got, []string{"alive"}) {
+				t.Fatalf("房间成员 %v，期望 [alive]", got)
+			}
+			return
+		default:
+		}
+		msgType, _, err := alive.next()
+		if err != nil {
+			t.Fatalf("回复心跳的客户端被断开: %v", err)
+		}
+		if msgType == codec.TypePing {
+			pings++
+			alive.write(codec.AppendPong(nil))
+		}
+	}
+}
+
+// 验证通过前同样回复 Ping
+func TestPingBeforeVerify(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	c := dial(t, s)
+	c.write(codec.AppendPing(nil))
+	msgType, _, err := c.next()
+	if err != nil || msgType != codec.TypePong {
+		t.Fatalf("收到 0x%X %v，期望 Pong", uint16(msgType), err)
+	}
+}
+
+// 关闭服务器后 Serve 返回 ErrServerClosed，新连接被拒绝
+func TestClose(t *testing.T) {
+	s := newServer(t, Config{OpenRooms: true})
+	c := join(t, s, "alice")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(ln) }()
+
+	s.Close()
+	c.expectClosed()
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve 返回 %v，期望 ErrServerClosed", err)
+	}
+	dial(t, s).expectClosed()
+}
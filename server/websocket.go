@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/xfs0205/fernqclient/internal/wsconn"
+)
+
+// ServeWebSocket 完成 WebSocket 升级后在连接上运行 FernQ 协议，可作为 http.HandlerFunc 使用
+//
+// 客户端使用 fernq+ws:// 或 fernq+wss:// 地址连接，codec 编码的帧以二进制消息承载。
+// 升级请求无效时回复 400。
+//
+// 使用方式:
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc(codec.DefaultWebSocketPath, srv.ServeWebSocket)
+//	go http.ListenAndServe(":8080", mux)
+func (s *Server) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := wsconn.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	s.ServeConn(ws)
+}
@@ -0,0 +1,127 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// SRV 记录的服务名与协议，查询的记录为 _fernq._tcp.<域名>
+const (
+	srvService = "fernq"
+	srvProto   = "tcp"
+)
+
+// Resolver SRV 记录解析器，*net.Resolver 满足该接口，测试时可替换为假的 DNS
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+// WithResolver 设置解析域名节点 SRV 记录使用的解析器
+//
+// 默认传输方式（TCP 直连）使用 net.DefaultResolver；通过 WithTransport 设置了代理、Unix 域套接字等
+// 其他传输方式时默认不查询 SRV 记录，域名原样交给传输方式，避免通过本地 DNS 泄露目标域名。
+// 此时如需 SRV 解析，使用该选项显式指定解析器，例如经由代理查询的 DNS。
+func WithResolver(r Resolver) Option {
+	return func(o *options) {
+		o.resolver = r
+	}
+}
+
+// 获取解析器，非 TCP 直连的传输方式未指定解析器时返回 nil，表示不查询 SRV 记录
+func (o *options) resolverOrDefault() Resolver {
+	if o.resolver != nil {
+		return o.resolver
+	}
+	switch o.transport.(type) {
+	case nil, *TCPTransport:
+		return net.DefaultResolver
+	}
+	return nil
+}
+
+// 解析节点的实际连接地址
+//
+// 未指定端口的域名节点（仅 fernq:// 与 fernqs://）按 _fernq._tcp.<域名> 的 SRV 记录展开，
+// 按优先级排序、同优先级按权重随机；没有 SRV 记录或没有可用的解析器时使用默认端口 9147。
+func (c *Client) resolveEndpoint(ctx context.Context, ep endpoint) []string {
+	if ep.scheme != codec.SchemeFernq && ep.scheme != codec.SchemeFernqs {
+		return []string{ep.address}
+	}
+	if _, _, err := net.SplitHostPort(ep.address); err == nil {
+		return []string{ep.address}
+	}
+
+	fallback := []string{net.JoinHostPort(ep.address, codec.DefaultPort)}
+	resolver := c.opts.resolverOrDefault()
+	if resolver == nil {
+		return fallback
+	}
+	_, records, err := resolver.LookupSRV(ctx, srvService, srvProto, ep.address)
+	if err != nil || len(records) == 0 {
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			log.Printf("解析 SRV 记录 _%s._%s.%s 失败: %v", srvService, srvProto, ep.address, err)
+		}
+		return fallback
+	}
+	// 按 RFC 2782，单条 "." 目标表示该服务不可用
+	if len(records) == 1 && records[0].Target == "." {
+		return fallback
+	}
+
+	addresses := make([]string, 0, len(records))
+	for _, srv := range orderSRV(records) {
+		target := strings.TrimSuffix(srv.Target, ".")
+		addresses = append(addresses, net.JoinHostPort(target, strconv.Itoa(int(srv.Port))))
+	}
+	return addresses
+}
+
+// 按 RFC 2782 排序 SRV 记录：优先级升序，同优先级内按权重加权随机
+//
+// 被选中的概率与权重成正比；权重为 0 的记录排在同优先级的其他记录之后，相互之间随机排列。
+func orderSRV(records []*net.SRV) []*net.SRV {
+	sorted := slices.Clone(records)
+	slices.SortStableFunc(sorted, func(a, b *net.SRV) int {
+		return int(a.Priority) - int(b.Priority)
+	})
+
+	ordered := make([]*net.SRV, 0, len(sorted))
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].Priority == sorted[i].Priority {
+			j++
+		}
+		group := sorted[i:j]
+		for len(group) > 0 {
+			total := 0
+			for _, srv := range group {
+				total += int(srv.Weight)
+			}
+			// 剩余记录权重均为 0 时等概率选择
+			pick := rand.IntN(len(group))
+			if total > 0 {
+				n := rand.IntN(total)
+				for k, srv := range group {
+					n -= int(srv.Weight)
+					if n < 0 {
+						pick = k
+						break
+					}
+				}
+			}
+			ordered = append(ordered, group[pick])
+			group = append(group[:pick:pick], group[pick+1:]...)
+		}
+		i = j
+	}
+	return ordered
+}
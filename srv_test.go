@@ -0,0 +1,164 @@
+package fernqclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/xfs0205/fernqclient/codec"
+)
+
+// 假的 DNS，按域名返回预设的 SRV 记录或错误
+type fakeResolver struct {
+	records map[string][]*net.SRV
+	err     error
+	lookups []string // 查询过的域名
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.lookups = append(r.lookups, "_"+service+"._"+proto+"."+name)
+	if r.err != nil {
+		return "", nil, r.err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return "", records, nil
+}
+
+// 使用假 DNS 解析节点
+func resolveWith(r Resolver, scheme, address string) []string {
+	c := NewClient("test", WithResolver(r))
+	return c.resolveEndpoint(context.Background(), endpoint{scheme: scheme, address: address})
+}
+
+func TestResolveEndpointPriority(t *testing.T) {
+	r := &fakeResolver{records: map[string][]*net.SRV{
+		"fernq.example.com": {
+			{Target: "backup.example.com.", Port: 9200, Priority: 20, Weight: 10},
+			{Target: "primary.example.com.", Port: 9100, Priority: 10, Weight: 10},
+		},
+	}}
+	got := resolveWith(r, codec.SchemeFernq, "fernq.example.com")
+	want := []string{"primary.example.com:9100", "backup.example.com:9200"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("得到 %v，期望 %v", got, want)
+	}
+	if !slices.Equal(r.lookups, []string{"_fernq._tcp.fernq.example.com"}) {
+		t.Fatalf("查询了 %v", r.lookups)
+	}
+}
+
+func TestResolveEndpointFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *fakeResolver
+	}{
+		{"NXDOMAIN", &fakeResolver{}},
+		{"查询失败", &fakeResolver{err: errors.New("timeout")}},
+		{"服务不可用", &fakeResolver{records: map[string][]*net.SRV{
+			"fernq.example.com": {{Target: ".", Port: 0}},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveWith(tt.r, codec.SchemeFernqs, "fernq.example.com")
+			if want := []string{"fernq.example.com:" + codec.DefaultPort}; !slices.Equal(got, want) {
+				t.Fatalf("得到 %v，期望 %v", got, want)
+			}
+		})
+	}
+}
+
+// 带端口的地址与 WebSocket 地址不查询 SRV
+func TestResolveEndpointSkip(t *testing.T) {
+	tests := []struct {
+		scheme  string
+		address string
+	}{
+		{codec.SchemeFernq, "fernq.example.com:9000"},
+		{codec.SchemeFernqWS, "fernq.example.com"},
+		{codec.SchemeFernqWSS, "fernq.example.com"},
+	}
+	for _, tt := range tests {
+		r := &fakeResolver{}
+		got := resolveWith(r, tt.scheme, tt.address)
+		if !slices.Equal(got, []string{tt.address}) || len(r.lookups) != 0 {
+			t.Errorf("%s %s: 得到 %v，查询了 %v", tt.scheme, tt.address, got, r.lookups)
+		}
+	}
+}
+
+// 同优先级内按权重随机，权重为 0 的记录排在最后但不会被丢弃
+func TestOrderSRVWeight(t *testing.T) {
+	const rounds = 4000
+	tests := []struct {
+		name    string
+		weights []uint16
+		first   []float64 // 每条记录排在第一位的期望比例
+	}{
+		{"按权重", []uint16{1, 3}, []float64{0.25, 0.75}},
+		{"全部为零", []uint16{0, 0}, []float64{0.5, 0.5}},
+		{"含零权重", []uint16{0, 5}, []float64{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := make([]*net.SRV, len(tt.weights))
+			for i, w := range tt.weights {
+				records[i] = &net.SRV{Target: string(rune('a' + i)), Priority: 10, Weight: w}
+			}
+			firsts := make([]int, len(records))
+			for range rounds {
+				ordered := orderSRV(records)
+				if len(ordered) != len(records) {
+					t.Fatalf("返回 %d 条记录，期望 %d 条", len(ordered), len(records))
+				}
+				firsts[slices.Index(records, ordered[0])]++
+			}
+			for i, want := range tt.first {
+				got := float64(firsts[i]) / rounds
+				if got < want-0.05 || got > want+0.05 {
+					t.Errorf("记录 %d 排在第一位的比例 %.3f，期望约 %.3f", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+// 非 TCP 直连的传输方式默认不查询 SRV 记录，显式指定解析器时查询
+func TestResolveEndpointTransport(t *testing.T) {
+	ep := endpoint{scheme: codec.SchemeFernq, address: "fernq.example.com"}
+	fallback := []string{"fernq.example.com:9147"}
+	proxies := []Transport{
+		&SOCKS5Transport{Proxy: "127.0.0.1:1080"},
+		&HTTPConnectTransport{Proxy: "127.0.0.1:3128"},
+		&UnixTransport{Path: "/tmp/fernq.sock"},
+	}
+	for _, tr := range proxies {
+		c := NewClient("test", WithTransport(tr))
+		if c.opts.resolverOrDefault() != nil {
+			t.Fatalf("%T 使用了默认解析器", tr)
+		}
+		if got := c.resolveEndpoint(context.Background(), ep); !slices.Equal(got, fallback) {
+			t.Fatalf("%T 得到 %v，期望 %v", tr, got, fallback)
+		}
+	}
+
+	for _, tr := range []Transport{nil, &TCPTransport{}} {
+		c := NewClient("test", WithTransport(tr))
+		if c.opts.resolverOrDefault() != net.DefaultResolver {
+			t.Fatalf("%T 未使用默认解析器", tr)
+		}
+	}
+
+	r := &fakeResolver{records: map[string][]*net.SRV{
+		"fernq.example.com": {{Target: "node.example.com.", Port: 9100}},
+	}}
+	c := NewClient("test", WithTransport(proxies[0]), WithResolver(r))
+	if got := c.resolveEndpoint(context.Background(), ep); !slices.Equal(got, []string{"node.example.com:9100"}) {
+		t.Fatalf("显式指定解析器时得到 %v", got)
+	}
+}
@@ -0,0 +1,117 @@
+package fernqclient
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// State 客户端连接状态
+type State int
+
+const (
+	StateClosed       State = iota // 未连接或已关闭
+	StateConnecting                // 正在建立连接
+	StateVerifying                 // 连接已建立，正在进行房间验证
+	StateConnected                 // 验证成功，可以收发消息
+	StateReconnecting              // 连接断开，等待断线重连
+)
+
+// String 返回状态名称
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "Closed"
+	case StateConnecting:
+		return "Connecting"
+	case StateVerifying:
+		return "Verifying"
+	case StateConnected:
+		return "Connected"
+	case StateReconnecting:
+		return "Reconnecting"
+	default:
+		return "Unknown"
+	}
+}
+
+// StateEvent 连接状态变化事件
+type StateEvent struct {
+	From State     // 变化前的状态
+	To   State     // 变化后的状态
+	Err  error     // 导致状态变化的错误或关闭原因，如 *AuthError、读取失败、ErrClosed；正常变化时为 nil
+	Time time.Time // 状态变化的时间
+}
+
+// State 返回当前连接状态
+func (c *Client) State() State {
+	c.statusMu.Lock()
+	defer c.statusMu.Unlock()
+	return c.state
+}
+
+// OnStateChange 订阅连接状态变化
+//
+// 参数:
+//   - fn: 状态变化时的回调函数，在状态变化的 goroutine 中同步调用，应尽快返回；
+//     可以在回调中调用 Connect，但不要在回调中阻塞等待 Stop()
+//
+// 返回值:
+//   - func(): 取消订阅函数
+//
+// 使用方式:
+//
+//	cancel := client.OnStateChange(func(ev fernqclient.StateEvent) {
+//	    log.Printf("%s -> %s: %v", ev.From, ev.To, ev.Err)
+//	})
+//	defer cancel()
+func (c *Client) OnStateChange(fn func(StateEvent)) func() {
+	c.listenersMu.Lock()
+	defer c.listenersMu.Unlock()
+	if c.listeners == nil {
+		c.listeners = make(map[int]func(StateEvent))
+	}
+	id := c.nextListener
+	c.nextListener++
+	c.listeners[id] = fn
+	return func() {
+		c.listenersMu.Lock()
+		delete(c.listeners, id)
+		c.listenersMu.Unlock()
+	}
+}
+
+// 切换状态并通知订阅者
+func (c *Client) setState(to State, err error) {
+	c.statusMu.Lock()
+	from := c.state
+	c.state = to
+	c.statusMu.Unlock()
+
+	ev := StateEvent{From: from, To: to, Err: err, Time: time.Now()}
+	c.listenersMu.Lock()
+	fns := make([]func(StateEvent), 0, len(c.listeners))
+	for _, fn := range c.listeners {
+		fns = append(fns, fn)
+	}
+	c.listenersMu.Unlock()
+	for _, fn := range fns {
+		fn(ev)
+	}
+}
+
+// 连接关闭器，记录第一个导致连接关闭的原因
+type connCloser struct {
+	conn net.Conn
+	once sync.Once
+	err  error
+}
+
+// 关闭连接并返回关闭原因，多次调用时以第一次的原因为准
+func (k *connCloser) close(err error) error {
+	k.once.Do(func() {
+		k.err = err
+		k.conn.Close()
+	})
+	return k.err
+}
@@ -0,0 +1,92 @@
+package fernqclient_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// 记录状态变化事件
+type stateRecorder struct {
+	mu     sync.Mutex
+	events []fernqclient.StateEvent
+}
+
+func (r *stateRecorder) record(ev fernqclient.StateEvent) {
+	r.mu.Lock()
+	r.events = append(r.events, ev)
+	r.mu.Unlock()
+}
+
+// 返回已记录的事件并清空
+func (r *stateRecorder) take() []fernqclient.StateEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+// 检查事件的目标状态序列，并且每个事件的 From 与上一个事件的 To 一致
+func checkStates(t *testing.T, events []fernqclient.StateEvent, from fernqclient.State, want ...fernqclient.State) {
+	t.Helper()
+	got := make([]fernqclient.State, len(events))
+	for i, ev := range events {
+		got[i] = ev.To
+		if ev.From != from {
+			t.Fatalf("第 %d 个事件 From 为 %s，期望 %s", i, ev.From, from)
+		}
+		from = ev.To
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("状态变化为 %v，期望 %v", got, want)
+	}
+}
+
+// 连接、断线重连与停止时依次通知状态变化
+func TestOnStateChange(t *testing.T) {
+	s := newServer(t)
+	c := fernqclient.NewClient("edge", fernqclient.WithReconnect(fastReconnect()))
+	rec := &stateRecorder{}
+	cancel := c.OnStateChange(rec.record)
+
+	if err := c.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	checkStates(t, rec.take(), fernqclient.StateClosed,
+		fernqclient.StateConnecting, fernqclient.StateVerifying, fernqclient.StateConnected)
+
+	// 服务器断开连接后重连
+	s.CloseClientConnections()
+	waitState(t, c, fernqclient.StateReconnecting)
+	waitState(t, c, fernqclient.StateConnected)
+	events := rec.take()
+	checkStates(t, events, fernqclient.StateConnected,
+		fernqclient.StateReconnecting, fernqclient.StateConnecting, fernqclient.StateVerifying, fernqclient.StateConnected)
+	if events[0].Err == nil {
+		t.Fatal("进入 Reconnecting 时没有记录断开原因")
+	}
+
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	events = rec.take()
+	checkStates(t, events, fernqclient.StateConnected, fernqclient.StateClosed)
+	if !errors.Is(events[0].Err, fernqclient.ErrClosed) {
+		t.Fatalf("停止时关闭原因为 %v，期望 ErrClosed", events[0].Err)
+	}
+
+	// 取消订阅后不再通知
+	cancel()
+	waitLeft(t, s, "edge")
+	if err := c.Connect(s.RoomURL("uuid", "test", "pass")); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	if events := rec.take(); len(events) != 0 {
+		t.Fatalf("取消订阅后仍收到 %d 个事件", len(events))
+	}
+}
@@ -0,0 +1,121 @@
+package fernqclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// WithTLS 使用 TLS 加密连接，fernqs:// 地址会自动启用 TLS，使用该选项可为 fernq:// 地址同样启用
+//
+// 参数:
+//   - config: TLS 配置，nil 表示使用默认配置（系统根证书）；
+//     未设置 ServerName 时自动使用节点主机名作为 SNI 与证书校验名
+//
+// 使用方式:
+//
+//	// 使用自签名 CA
+//	pool := x509.NewCertPool()
+//	pool.AppendCertsFromPEM(caPEM)
+//	client := fernqclient.NewClient("alice", fernqclient.WithTLS(&tls.Config{RootCAs: pool}))
+//	err := client.Connect("fernqs://connect/room.example.com:9148/uuid#room?room_pass=secret")
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		o.tlsConfig = config
+	}
+}
+
+// WithPinnedPublicKeys 固定服务器证书公钥，仅当叶子证书的公钥指纹在列表中时才允许连接
+//
+// 参数:
+//   - pins: 证书 SubjectPublicKeyInfo 的 SHA-256 摘要的 base64 编码，
+//     可带 "sha256//" 前缀（与 curl --pinnedpubkey 格式一致），可使用 PublicKeyPin 计算
+//
+// 说明:
+//   - 公钥校验在证书链校验之后进行，两者都通过才允许连接
+//   - 使用不受信任的自签名证书时，可配合 WithTLS(&tls.Config{InsecureSkipVerify: true})
+//     跳过证书链校验，仅依赖公钥固定
+func WithPinnedPublicKeys(pins ...string) Option {
+	return func(o *options) {
+		o.tlsPins = append(o.tlsPins, pins...)
+	}
+}
+
+// PublicKeyPin 计算证书公钥指纹，格式为 "sha256//" + base64(SHA-256(SubjectPublicKeyInfo))
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256//" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// 在已建立的连接上完成 TLS 握手
+func (c *Client) tlsHandshake(ctx context.Context, conn net.Conn, serverAddr string) (net.Conn, error) {
+	config, err := c.opts.tlsClientConfig(serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS握手失败: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// 生成本次连接使用的 TLS 配置
+func (o *options) tlsClientConfig(serverAddr string) (*tls.Config, error) {
+	var config *tls.Config
+	if o.tlsConfig != nil {
+		config = o.tlsConfig.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	// 使用节点主机名作为 SNI
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(serverAddr)
+		if err != nil {
+			host = serverAddr
+		}
+		config.ServerName = strings.Trim(host, "[]")
+	}
+
+	if len(o.tlsPins) == 0 {
+		return config, nil
+	}
+
+	pins := make([][]byte, 0, len(o.tlsPins))
+	for _, pin := range o.tlsPins {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256//"))
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("无效的公钥指纹 '%s'", pin)
+		}
+		pins = append(pins, sum)
+	}
+	verify := config.VerifyConnection
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		if len(cs.PeerCertificates) == 0 {
+			return fmt.Errorf("服务器未提供证书")
+		}
+		sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(pin, sum[:]) {
+				return nil
+			}
+		}
+		return fmt.Errorf("服务器证书公钥与固定的指纹不匹配")
+	}
+	return config, nil
+}
@@ -0,0 +1,115 @@
+package fernqclient_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/server"
+)
+
+// 生成 127.0.0.1 的自签名证书
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fernq test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+// 启动使用自签名证书的 TLS 服务器，返回 fernqs:// 地址
+func newTLSServer(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(server.Config{OpenRooms: true})
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return fmt.Sprintf("fernqs://connect/%s/uuid#test?room_pass=pass", ln.Addr())
+}
+
+func TestTLS(t *testing.T) {
+	cert, leaf := selfSignedCert(t)
+	url := newTLSServer(t, cert)
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	_, other := selfSignedCert(t)
+
+	tests := []struct {
+		name string
+		opts []fernqclient.Option
+		ok   bool
+	}{
+		{"系统根证书", nil, false},
+		{"RootCAs", []fernqclient.Option{fernqclient.WithTLS(&tls.Config{RootCAs: pool})}, true},
+		{"RootCAs+公钥匹配", []fernqclient.Option{
+			fernqclient.WithTLS(&tls.Config{RootCAs: pool}),
+			fernqclient.WithPinnedPublicKeys(fernqclient.PublicKeyPin(leaf)),
+		}, true},
+		{"RootCAs+公钥不匹配", []fernqclient.Option{
+			fernqclient.WithTLS(&tls.Config{RootCAs: pool}),
+			fernqclient.WithPinnedPublicKeys(fernqclient.PublicKeyPin(other)),
+		}, false},
+		{"跳过证书链+公钥匹配", []fernqclient.Option{
+			fernqclient.WithTLS(&tls.Config{InsecureSkipVerify: true}),
+			fernqclient.WithPinnedPublicKeys(fernqclient.PublicKeyPin(leaf)),
+		}, true},
+		{"跳过证书链+公钥不匹配", []fernqclient.Option{
+			fernqclient.WithTLS(&tls.Config{InsecureSkipVerify: true}),
+			fernqclient.WithPinnedPublicKeys(fernqclient.PublicKeyPin(other)),
+		}, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fernqclient.NewClient(fmt.Sprintf("client-%d", i), tt.opts...)
+			err := c.Connect(url)
+			if err == nil {
+				defer c.Stop()
+			}
+			if tt.ok != (err == nil) {
+				t.Fatalf("Connect 返回 %v，期望成功: %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			// 连接可用：广播会发回自己
+			if err := c.Broadcast([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			if msg := receive(t, c); string(msg.Message) != "ping" {
+				t.Fatalf("收到 %q，期望 \"ping\"", msg.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package fernqclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Transport 底层连接的建立方式，帧编码与房间验证流程与传输方式无关
+//
+// 可用于 Unix 域套接字、SOCKS5/HTTP CONNECT 代理、内存连接(net.Pipe)或自定义隧道。
+type Transport interface {
+	// Dial 建立到节点的连接
+	//   - address: 从 fernq URL 中解析出的节点地址，如 "192.168.1.100:9147"
+	Dial(ctx context.Context, address string) (net.Conn, error)
+}
+
+// TransportFunc 将普通函数适配为 Transport
+//
+// 使用方式:
+//
+//	// 使用 net.Pipe 在内存中连接测试服务器
+//	transport := fernqclient.TransportFunc(func(ctx context.Context, address string) (net.Conn, error) {
+//	    client, server := net.Pipe()
+//	    go testServer.ServeConn(server)
+//	    return client, nil
+//	})
+//	client := fernqclient.NewClient("alice", fernqclient.WithTransport(transport))
+type TransportFunc func(ctx context.Context, address string) (net.Conn, error)
+
+// Dial 调用函数本身
+func (f TransportFunc) Dial(ctx context.Context, address string) (net.Conn, error) {
+	return f(ctx, address)
+}
+
+// WithTransport 设置底层传输方式，默认为 TCPTransport
+//
+// WithDialTimeout 对所有传输方式生效；fernqs:// 或 WithTLS 的 TLS 加密建立在传输层连接之上。
+// 使用 TCPTransport 以外的传输方式时默认不查询 SRV 记录，见 WithResolver。
+func WithTransport(t Transport) Option {
+	return func(o *options) {
+		o.transport = t
+	}
+}
+
+// 获取传输方式
+func (o *options) transportOrDefault() Transport {
+	if o.transport != nil {
+		return o.transport
+	}
+	return &TCPTransport{}
+}
+
+// TCPTransport 通过 TCP 直连节点，默认的传输方式
+type TCPTransport struct {
+	Dialer net.Dialer // 拨号器，可设置本地地址、TCP KeepAlive 等
+}
+
+// Dial 建立 TCP 连接
+func (t *TCPTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	return t.Dialer.DialContext(ctx, "tcp", address)
+}
+
+// UnixTransport 通过 Unix 域套接字连接本机节点，忽略 URL 中的节点地址
+type UnixTransport struct {
+	Path string // 套接字文件路径
+}
+
+// Dial 建立 Unix 域套接字连接
+func (t *UnixTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", t.Path)
+}
+
+// SOCKS5Transport 通过 SOCKS5 代理连接节点 (RFC 1928)，域名由代理解析
+type SOCKS5Transport struct {
+	Proxy    string    // 代理地址，如 "127.0.0.1:1080"
+	Username string    // 用户名，为空表示无需认证 (RFC 1929)
+	Password string    // 密码
+	Forward  Transport // 连接代理使用的传输方式，nil 表示 TCP 直连
+}
+
+// Dial 通过代理建立连接
+func (t *SOCKS5Transport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5: 无效的目标地址 '%s': %w", address, err)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5: 无效的目标端口 '%s'", portStr)
+	}
+
+	conn, err := dialForward(ctx, t.Forward, t.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("SOCKS5: 连接代理失败: %w", err)
+	}
+	if err := withConnContext(ctx, conn, func() error {
+		return t.connect(conn, host, uint16(port))
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// SOCKS5 握手并发送 CONNECT 请求
+func (t *SOCKS5Transport) connect(conn net.Conn, host string, port uint16) error {
+	// 1. 协商认证方式
+	methods := []byte{0x00} // 无需认证
+	if t.Username != "" {
+		methods = []byte{0x02} // 用户名密码认证
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("SOCKS5: 发送握手失败: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("SOCKS5: 读取握手失败: %w", err)
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("SOCKS5: 不支持的协议版本 %d", reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		// 2. 用户名密码认证
+		if len(t.Username) > 255 || len(t.Password) > 255 {
+			return fmt.Errorf("SOCKS5: 用户名或密码过长")
+		}
+		auth := []byte{0x01, byte(len(t.Username))}
+		auth = append(auth, t.Username...)
+		auth = append(auth, byte(len(t.Password)))
+		auth = append(auth, t.Password...)
+		if _, err := conn.Write(auth); err != nil {
+			return fmt.Errorf("SOCKS5: 发送认证失败: %w", err)
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("SOCKS5: 读取认证结果失败: %w", err)
+		}
+		if reply[1] != 0x00 {
+			return fmt.Errorf("SOCKS5: 认证失败")
+		}
+	default:
+		return fmt.Errorf("SOCKS5: 代理不接受可用的认证方式")
+	}
+
+	// 3. 发送 CONNECT 请求
+	req := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(req, 0x01)
+			req = append(req, ip4...)
+		} else {
+			req = append(req, 0x04)
+			req = append(req, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("SOCKS5: 域名过长")
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("SOCKS5: 发送连接请求失败: %w", err)
+	}
+
+	// 4. 读取结果: VER REP RSV ATYP BND.ADDR BND.PORT
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return fmt.Errorf("SOCKS5: 读取连接结果失败: %w", err)
+	}
+	if head[1] != 0x00 {
+		return fmt.Errorf("SOCKS5: 代理拒绝连接，错误码 %d", head[1])
+	}
+	var addrLen int
+	switch head[3] {
+	case 0x01:
+		addrLen = net.IPv4len
+	case 0x04:
+		addrLen = net.IPv6len
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return fmt.Errorf("SOCKS5: 读取连接结果失败: %w", err)
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("SOCKS5: 未知的地址类型 %d", head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, addrLen+2)); err != nil {
+		return fmt.Errorf("SOCKS5: 读取连接结果失败: %w", err)
+	}
+	return nil
+}
+
+// HTTPConnectTransport 通过 HTTP 代理的 CONNECT 方法建立隧道连接节点
+type HTTPConnectTransport struct {
+	Proxy    string      // 代理地址，如 "proxy.example.com:3128"
+	Username string      // 用户名，为空表示无需认证 (Basic)
+	Password string      // 密码
+	Header   http.Header // 附加的请求头
+	Forward  Transport   // 连接代理使用的传输方式，nil 表示 TCP 直连
+}
+
+// Dial 通过代理建立隧道
+func (t *HTTPConnectTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := dialForward(ctx, t.Forward, t.Proxy)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP CONNECT: 连接代理失败: %w", err)
+	}
+	var tunnel net.Conn
+	if err := withConnContext(ctx, conn, func() error {
+		tunnel, err = t.connect(conn, address)
+		return err
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// 发送 CONNECT 请求并检查代理响应
+func (t *HTTPConnectTransport) connect(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	for k, v := range t.Header {
+		req.Header[k] = v
+	}
+	if t.Username != "" {
+		cred := base64.StdEncoding.EncodeToString([]byte(t.Username + ":" + t.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+cred)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("HTTP CONNECT: 发送请求失败: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP CONNECT: 读取响应失败: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP CONNECT: 代理返回 %s", resp.Status)
+	}
+
+	// 代理可能在响应后紧跟隧道数据，保留已缓冲的部分
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// 带读缓冲的连接
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read 优先读取缓冲中的数据
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// 使用上游传输方式连接代理
+func dialForward(ctx context.Context, forward Transport, address string) (net.Conn, error) {
+	if forward == nil {
+		forward = &TCPTransport{}
+	}
+	return forward.Dial(ctx, address)
+}
+
+// 在 ctx 的截止时间与取消控制下执行代理握手，结束后清除超时
+func withConnContext(ctx context.Context, conn net.Conn, fn func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	err := fn()
+	if !stop() {
+		return ctx.Err()
+	}
+	if err != nil {
+		return err
+	}
+	return conn.SetDeadline(time.Time{})
+}
@@ -0,0 +1,318 @@
+package fernqclient_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+	"github.com/xfs0205/fernqclient/fernqtest"
+)
+
+// 代理测试中节点使用的域名，由代理解析为 127.0.0.1
+const proxyHost = "fernq.test"
+
+// 通过域名连接测试服务器的地址，端口与服务器相同
+func proxyURL(s *fernqtest.Server) string {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	return fmt.Sprintf("fernq://connect/%s:%s/uuid#test?room_pass=pass", proxyHost, port)
+}
+
+// 代理解析目标地址，只接受 proxyHost
+func proxyResolve(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if host != proxyHost {
+		return "", fmt.Errorf("未知的主机 %s", host)
+	}
+	return net.JoinHostPort("127.0.0.1", port), nil
+}
+
+// 双向转发，任一方向结束时关闭两端
+func relay(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+		b.Close()
+	}()
+	io.Copy(b, a)
+	a.Close()
+	b.Close()
+}
+
+// 在本地端口上运行 handle，返回监听地址
+func listen(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// 启动进程内的 SOCKS5 代理，username 不为空时要求用户名密码认证
+func socks5Proxy(t *testing.T, username, password string) string {
+	return listen(t, func(conn net.Conn) {
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		head := make([]byte, 2)
+		if _, err := io.ReadFull(br, head); err != nil {
+			return
+		}
+		methods := make([]byte, head[1])
+		io.ReadFull(br, methods)
+		switch {
+		case username == "":
+			conn.Write([]byte{0x05, 0x00})
+		case !bytes.Contains(methods, []byte{0x02}):
+			conn.Write([]byte{0x05, 0xFF}) // 没有可接受的认证方式
+			return
+		default:
+			conn.Write([]byte{0x05, 0x02})
+			var ulen, plen [1]byte
+			io.ReadFull(br, head[:1])
+			io.ReadFull(br, ulen[:])
+			user := make([]byte, ulen[0])
+			io.ReadFull(br, user)
+			io.ReadFull(br, plen[:])
+			pass := make([]byte, plen[0])
+			io.ReadFull(br, pass)
+			if string(user) != username || string(pass) != password {
+				conn.Write([]byte{0x01, 0x01})
+				return
+			}
+			conn.Write([]byte{0x01, 0x00})
+		}
+
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(br, req); err != nil {
+			return
+		}
+		var host string
+		switch req[3] {
+		case 0x01:
+			ip := make([]byte, 4)
+			io.ReadFull(br, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			l, _ := br.ReadByte()
+			name := make([]byte, l)
+			io.ReadFull(br, name)
+			host = string(name)
+		default:
+			return
+		}
+		port := make([]byte, 2)
+		io.ReadFull(br, port)
+		address := net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port)))
+
+		reply := []byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+		target, err := proxyResolve(address)
+		var upstream net.Conn
+		if err == nil {
+			upstream, err = net.Dial("tcp", target)
+		}
+		if err != nil {
+			reply[1] = 0x05 // 连接被拒绝
+			conn.Write(reply)
+			return
+		}
+		conn.Write(reply)
+		relay(&bufferedConn{Conn: conn, r: br}, upstream)
+	})
+}
+
+// 带读缓冲的连接
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+// 启动进程内的 HTTP CONNECT 代理，username 不为空时要求 Basic 认证
+func httpConnectProxy(t *testing.T, username, password string) string {
+	t.Helper()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		if username != "" {
+			want := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+			if r.Header.Get("Proxy-Authorization") != want {
+				http.Error(w, "auth required", http.StatusProxyAuthRequired)
+				return
+			}
+		}
+		target, err := proxyResolve(r.Host)
+		var upstream net.Conn
+		if err == nil {
+			upstream, err = net.Dial("tcp", target)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		brw.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+		brw.Flush()
+		relay(&bufferedConn{Conn: conn, r: brw.Reader}, upstream)
+	}))
+	t.Cleanup(hs.Close)
+	return strings.TrimPrefix(hs.URL, "http://")
+}
+
+// 通过传输方式连接测试服务器并给自己发送一条消息
+func roundTrip(t *testing.T, s *fernqtest.Server, url string, tr fernqclient.Transport) error {
+	t.Helper()
+	c := fernqclient.NewClient("alice", fernqclient.WithTransport(tr))
+	if err := c.Connect(url); err != nil {
+		return err
+	}
+	defer c.Stop()
+	if err := c.Send("alice", []byte("via proxy")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := receive(t, c); string(msg.Message) != "via proxy" {
+		t.Fatalf("收到 %q", msg.Message)
+	}
+	return nil
+}
+
+// SOCKS5 代理：域名由代理解析，认证失败与代理拒绝连接时返回错误
+func TestSOCKS5Transport(t *testing.T) {
+	s := newServer(t)
+	open, auth := socks5Proxy(t, "", ""), socks5Proxy(t, "user", "secret")
+	down := fmt.Sprintf("fernq://connect/%s:1/uuid#test?room_pass=pass", proxyHost)
+
+	tests := []struct {
+		name string
+		tr   *fernqclient.SOCKS5Transport
+		url  string
+		want string
+	}{
+		{"no auth", &fernqclient.SOCKS5Transport{Proxy: open}, proxyURL(s), ""},
+		{"auth", &fernqclient.SOCKS5Transport{Proxy: auth, Username: "user", Password: "secret"}, proxyURL(s), ""},
+		{"wrong password", &fernqclient.SOCKS5Transport{Proxy: auth, Username: "user", Password: "wrong"}, proxyURL(s), "认证失败"},
+		{"no credentials", &fernqclient.SOCKS5Transport{Proxy: auth}, proxyURL(s), "认证方式"},
+		{"target down", &fernqclient.SOCKS5Transport{Proxy: open}, down, "代理拒绝连接"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := roundTrip(t, s, tt.url, tt.tr)
+			if tt.want == "" && err != nil {
+				t.Fatalf("连接失败: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("错误为 %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// HTTP CONNECT 代理：代理返回非 200 时连接失败并带上状态
+func TestHTTPConnectTransport(t *testing.T) {
+	s := newServer(t)
+	open, auth := httpConnectProxy(t, "", ""), httpConnectProxy(t, "user", "secret")
+	down := fmt.Sprintf("fernq://connect/%s:1/uuid#test?room_pass=pass", proxyHost)
+
+	tests := []struct {
+		name string
+		tr   *fernqclient.HTTPConnectTransport
+		url  string
+		want string
+	}{
+		{"no auth", &fernqclient.HTTPConnectTransport{Proxy: open}, proxyURL(s), ""},
+		{"auth", &fernqclient.HTTPConnectTransport{Proxy: auth, Username: "user", Password: "secret"}, proxyURL(s), ""},
+		{"wrong password", &fernqclient.HTTPConnectTransport{Proxy: auth, Username: "user", Password: "wrong"}, proxyURL(s), "407"},
+		{"target down", &fernqclient.HTTPConnectTransport{Proxy: open}, down, "502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := roundTrip(t, s, tt.url, tt.tr)
+			if tt.want == "" && err != nil {
+				t.Fatalf("连接失败: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Fatalf("错误为 %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// Unix 域套接字：忽略 URL 中的节点地址
+func TestUnixTransport(t *testing.T) {
+	s := newServer(t)
+	path := filepath.Join(t.TempDir(), "fernq.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	go s.Serve(ln)
+
+	url := "fernq://connect/unused.invalid:9147/uuid#test?room_pass=pass"
+	if err := roundTrip(t, s, url, &fernqclient.UnixTransport{Path: path}); err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+}
+
+// 代理握手期间取消上下文时立即返回
+func TestTransportDialCancel(t *testing.T) {
+	// 接受连接后不响应的代理
+	silent := listen(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+		conn.Close()
+	})
+	tests := []fernqclient.Transport{
+		&fernqclient.SOCKS5Transport{Proxy: silent},
+		&fernqclient.HTTPConnectTransport{Proxy: silent},
+	}
+	for _, tr := range tests {
+		t.Run(fmt.Sprintf("%T", tr), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			conn, err := tr.Dial(ctx, proxyHost+":9147")
+			if err == nil {
+				conn.Close()
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("返回 %v，期望 context.Canceled", err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Fatalf("取消后 %v 才返回", d)
+			}
+		})
+	}
+}
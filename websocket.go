@@ -0,0 +1,204 @@
+package fernqclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/xfs0205/fernqclient/codec"
+	"github.com/xfs0205/fernqclient/internal/wsconn"
+)
+
+// DefaultWebSocketPath WebSocket 传输默认的请求路径
+const DefaultWebSocketPath = codec.DefaultWebSocketPath
+
+// WebSocketTransport 通过 WebSocket 连接节点，codec.Encode 编码的帧以二进制消息承载，
+// 适用于只允许 HTTP(S) 出站的网络环境
+//
+// 使用 fernq+ws:// 或 fernq+wss:// 地址时自动启用，无需手动设置；
+// 通过 WithTransport 传入时作为模板，可自定义路径、请求头和上游传输方式：
+//
+//	client := fernqclient.NewClient("alice", fernqclient.WithTransport(&fernqclient.WebSocketTransport{
+//	    Path:    "/gateway/fernq",
+//	    Forward: &fernqclient.HTTPConnectTransport{Proxy: "proxy.example.com:3128"},
+//	}))
+//	err := client.Connect("fernq+wss://connect/room.example.com/uuid#room?room_pass=secret")
+//
+// 节点地址省略端口时，域名使用 80 (ws) 或 443 (wss)，IP 地址仍使用默认端口 9147。
+type WebSocketTransport struct {
+	Path      string      // 请求路径，默认 DefaultWebSocketPath
+	Header    http.Header // 附加请求头，如 Authorization、Origin
+	Secure    bool        // 是否使用 TLS (wss)，使用 fernq+ws(s):// 地址时由协议决定
+	TLSConfig *tls.Config // TLS 配置，nil 时使用 WithTLS 的配置；未设置 ServerName 时使用节点主机名
+	Forward   Transport   // 建立底层连接使用的传输方式，nil 表示 TCP 直连
+}
+
+// Dial 建立 WebSocket 连接
+func (t *WebSocketTransport) Dial(ctx context.Context, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		// 域名无端口时使用 HTTP 默认端口
+		host = address
+		if t.Secure {
+			address = net.JoinHostPort(address, "443")
+		} else {
+			address = net.JoinHostPort(address, "80")
+		}
+	}
+
+	conn, err := dialForward(ctx, t.Forward, address)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket: 连接失败: %w", err)
+	}
+
+	if t.Secure {
+		var config *tls.Config
+		if t.TLSConfig != nil {
+			config = t.TLSConfig.Clone()
+		} else {
+			config = &tls.Config{}
+		}
+		if config.ServerName == "" {
+			config.ServerName = strings.Trim(host, "[]")
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("WebSocket: TLS握手失败: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	var ws net.Conn
+	if err := withConnContext(ctx, conn, func() error {
+		ws, err = t.handshake(conn, address)
+		return err
+	}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// 发送升级请求并校验服务端响应
+func (t *WebSocketTransport) handshake(conn net.Conn, address string) (net.Conn, error) {
+	path := t.Path
+	if path == "" {
+		path = DefaultWebSocketPath
+	}
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket: 无效的路径 '%s': %w", path, err)
+	}
+	u.Scheme = "http"
+	u.Host = address
+
+	key, err := wsconn.NewKey()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    u,
+		Host:   address,
+		Header: make(http.Header),
+	}
+	for k, v := range t.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("WebSocket: 发送升级请求失败: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("WebSocket: 读取升级响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, fmt.Errorf("WebSocket: 服务端返回 %s", resp.Status)
+	}
+	if !strings.EqualFold(resp.Header.Get("Upgrade"), "websocket") ||
+		resp.Header.Get("Sec-WebSocket-Accept") != wsconn.AcceptKey(key) {
+		return nil, fmt.Errorf("WebSocket: 无效的升级响应")
+	}
+	return wsconn.New(conn, br, true), nil
+}
+
+// 为 fernq+ws(s):// 地址生成 WebSocket 传输方式
+func (c *Client) webSocketTransport(scheme, serverAddr string) (Transport, error) {
+	var ws WebSocketTransport
+	if t, ok := c.opts.transport.(*WebSocketTransport); ok {
+		ws = *t
+	} else {
+		ws.Forward = c.opts.transport
+	}
+	ws.Secure = scheme == codec.SchemeFernqWSS
+	if ws.Secure && ws.TLSConfig == nil && (c.opts.tlsConfig != nil || len(c.opts.tlsPins) > 0) {
+		config, err := c.opts.tlsClientConfig(serverAddr)
+		if err != nil {
+			return nil, err
+		}
+		ws.TLSConfig = config
+	}
+	return &ws, nil
+}
+
+// WebSocketBridge 将 WebSocket 连接桥接到 FernQ 节点的 TCP 端口，实现 http.Handler
+//
+// 可部署在只开放 HTTP(S) 的入口之后，也可用于在本地测试 WebSocket 传输：
+//
+//	http.Handle(fernqclient.DefaultWebSocketPath, &fernqclient.WebSocketBridge{Target: "127.0.0.1:9147"})
+//	go http.ListenAndServe(":8080", nil)
+//	err := client.Connect("fernq+ws://connect/127.0.0.1:8080/uuid#room?room_pass=secret")
+//
+// 基于 server 包的服务器可直接使用 Server.ServeWebSocket 接受 WebSocket 连接，无需桥接。
+type WebSocketBridge struct {
+	Target    string    // 转发目标节点地址，如 "127.0.0.1:9147"
+	Transport Transport // 连接目标节点的传输方式，nil 表示 TCP 直连
+}
+
+// ServeHTTP 完成 WebSocket 升级并双向转发数据，任一方向结束时关闭两端连接
+func (b *WebSocketBridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := wsconn.CheckUpgrade(r); err != nil {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	upstream, err := dialForward(r.Context(), b.Transport, b.Target)
+	if err != nil {
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+
+	ws, err := wsconn.Upgrade(w, r)
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, ws)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(ws, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+	ws.Close()
+	upstream.Close()
+	<-done
+}
@@ -0,0 +1,173 @@
+package fernqclient_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xfs0205/fernqclient"
+)
+
+// WebSocket 地址
+func wsURL(scheme, httpURL string) string {
+	host := httpURL[strings.Index(httpURL, "://")+3:]
+	return fmt.Sprintf("%s://connect/%s/uuid#test?room_pass=pass", scheme, host)
+}
+
+// 通过 WebSocketBridge 与服务器直接接受 WebSocket 两种方式连接，大消息跨越多个 WebSocket 帧与读缓冲，开启心跳
+func TestWebSocket(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(t *testing.T) http.Handler
+	}{
+		{"bridge", func(t *testing.T) http.Handler {
+			return &fernqclient.WebSocketBridge{Target: newServer(t).Addr}
+		}},
+		{"server", func(t *testing.T) http.Handler {
+			return http.HandlerFunc(newServer(t).ServeWebSocket)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := httptest.NewServer(tt.handler(t))
+			defer hs.Close()
+
+			var clients []*fernqclient.Client
+			for _, name := range []string{"alice", "bob"} {
+				c := fernqclient.NewClient(name, fernqclient.WithKeepalive(20*time.Millisecond, 3))
+				if err := c.Connect(wsURL("fernq+ws", hs.URL)); err != nil {
+					t.Fatalf("%s 连接失败: %v", name, err)
+				}
+				defer c.Stop()
+				clients = append(clients, c)
+			}
+
+			large := bytes.Repeat([]byte("0123456789"), 20<<10)
+			if err := clients[0].Broadcast(large); err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range clients {
+				if msg := receive(t, c); msg.From != "alice" || !bytes.Equal(msg.Message, large) {
+					t.Fatalf("%s 收到 %s 的 %d 字节，期望 alice 的 %d 字节", c.ClientName, msg.From, len(msg.Message), len(large))
+				}
+			}
+
+			// 经过多个心跳周期后连接仍然可用
+			time.Sleep(100 * time.Millisecond)
+			if err := clients[1].Send("alice", []byte("still here")); err != nil {
+				t.Fatal(err)
+			}
+			if msg := receive(t, clients[0]); string(msg.Message) != "still here" {
+				t.Fatalf("收到 %q", msg.Message)
+			}
+		})
+	}
+}
+
+// fernq+wss:// 使用 WithTLS 的配置校验证书
+func TestWebSocketTLS(t *testing.T) {
+	s := newServer(t)
+	hs := httptest.NewTLSServer(&fernqclient.WebSocketBridge{Target: s.Addr})
+	defer hs.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(hs.Certificate())
+
+	tests := []struct {
+		name string
+		tls  *tls.Config
+		ok   bool
+	}{
+		{"trusted", &tls.Config{RootCAs: roots}, true},
+		{"untrusted", nil, false},
+		{"insecure", &tls.Config{InsecureSkipVerify: true}, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []fernqclient.Option
+			if tt.tls != nil {
+				opts = append(opts, fernqclient.WithTLS(tt.tls))
+			}
+			c := fernqclient.NewClient(fmt.Sprintf("client-%d", i), opts...)
+			err := c.Connect(wsURL("fernq+wss", hs.URL))
+			if err == nil {
+				defer c.Stop()
+			}
+			if (err == nil) != tt.ok {
+				t.Fatalf("连接结果 %v，期望成功 %v", err, tt.ok)
+			}
+			if tt.ok {
+				if err := c.Send(c.ClientName, []byte("over wss")); err != nil {
+					t.Fatal(err)
+				}
+				if msg := receive(t, c); string(msg.Message) != "over wss" {
+					t.Fatalf("收到 %q", msg.Message)
+				}
+			}
+		})
+	}
+}
+
+// 升级失败时 Connect 返回错误，桥接拒绝无效的升级请求
+func TestWebSocketBadHandshake(t *testing.T) {
+	// 接管连接后返回错误 Sec-WebSocket-Accept 的服务端
+	badAccept := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: wrong\r\n\r\n")
+		brw.Flush()
+	})
+	// 上游不可达的桥接
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadTarget := ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		want    string
+	}{
+		{"not found", http.NotFoundHandler(), "404"},
+		{"bad accept", badAccept, "无效的升级响应"},
+		{"upstream down", &fernqclient.WebSocketBridge{Target: deadTarget}, "502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hs := httptest.NewServer(tt.handler)
+			defer hs.Close()
+			c := fernqclient.NewClient("alice")
+			err := c.Connect(wsURL("fernq+ws", hs.URL))
+			if err == nil {
+				c.Stop()
+				t.Fatal("升级失败时连接成功")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("错误为 %v，期望包含 %q", err, tt.want)
+			}
+		})
+	}
+
+	// 普通 HTTP 请求访问桥接时回复 400
+	hs := httptest.NewServer(&fernqclient.WebSocketBridge{Target: newServer(t).Addr})
+	defer hs.Close()
+	resp, err := http.Get(hs.URL + fernqclient.DefaultWebSocketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("状态码 %d，期望 400", resp.StatusCode)
+	}
+}